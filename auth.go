@@ -0,0 +1,295 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	authBasic  = "basic"
+	authDigest = "digest"
+	authBearer = "bearer"
+)
+
+// authOptions authenticate the request for the watched page. ${NAME} in the
+// credentials is replaced by the environment variable NAME.
+type authOptions struct {
+	// Type is basic, digest or bearer
+	Type     string `yaml:"type"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Token is sent as "Authorization: Bearer <token>"
+	Token string `yaml:"token"`
+}
+
+func (options authOptions) validate() error {
+	switch strings.ToLower(options.Type) {
+	case "":
+		return nil
+	case authBasic, authDigest:
+		if options.Username == "" {
+			return fmt.Errorf("Please specify a username for %s authentication", strings.ToLower(options.Type))
+		}
+		return nil
+	case authBearer:
+		if options.Token == "" {
+			return fmt.Errorf("Please specify a token for bearer authentication")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("Unknown authentication: %s", options.Type)
+}
+
+// authorize adds the credentials for basic and bearer authentication to
+// request, digest authentication is answered by digestTransport
+func (options authOptions) authorize(request *http.Request) error {
+	switch strings.ToLower(options.Type) {
+	case authBasic:
+		username, password, err := options.credentials()
+		if err != nil {
+			return err
+		}
+		request.SetBasicAuth(username, password)
+	case authBearer:
+		token, err := interpolate(options.Token, nil)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return nil
+}
+
+func (options authOptions) credentials() (string, string, error) {
+	username, err := interpolate(options.Username, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err := interpolate(options.Password, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
+// clientTLSOptions configure the TLS connection to the watched page
+type clientTLSOptions struct {
+	// CAFile holds the PEM encoded certificates trusted instead of the system
+	// ones
+	CAFile string `yaml:"caFile"`
+	// CertFile and KeyFile hold the PEM encoded client certificate and its
+	// key for mutual TLS
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// ServerName is the name the server certificate has to match, defaults
+	// to the host of the URL
+	ServerName         string `yaml:"serverName"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+func (options clientTLSOptions) enabled() bool {
+	return options != clientTLSOptions{}
+}
+
+func (options clientTLSOptions) validate() error {
+	if (options.CertFile == "") != (options.KeyFile == "") {
+		return fmt.Errorf("Please specify both certFile and keyFile")
+	}
+
+	return nil
+}
+
+func (options clientTLSOptions) config() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         options.ServerName,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+
+	if options.CAFile != "" {
+		pem, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA file: %s", err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in CA file: %s", options.CAFile)
+		}
+	}
+
+	if options.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// digestTransport answers digest authentication challenges by sending the
+// request again with the credentials
+type digestTransport struct {
+	options authOptions
+	next    http.RoundTripper
+}
+
+func (t *digestTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.next.RoundTrip(request)
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+
+	challenge, found := digestChallenge(response.Header.Values("WWW-Authenticate"))
+	if !found || request.Body != nil && request.GetBody == nil {
+		return response, nil
+	}
+
+	username, password, err := t.options.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	authorization, err := digestAuthorization(challenge, request.Method, request.URL.RequestURI(), username, password, newCnonce())
+	if err != nil {
+		return nil, err
+	}
+
+	retry := request.Clone(request.Context())
+	if request.GetBody != nil {
+		retry.Body, err = request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	retry.Header.Set("Authorization", authorization)
+	response.Body.Close()
+
+	return t.next.RoundTrip(retry)
+}
+
+// digestChallenge finds the parameters of a digest challenge among the
+// WWW-Authenticate headers
+func digestChallenge(headers []string) (map[string]string, bool) {
+	for _, header := range headers {
+		fields := strings.SplitN(strings.TrimSpace(header), " ", 2)
+		if len(fields) == 2 && strings.EqualFold(fields[0], "Digest") {
+			return parseAuthParams(fields[1]), true
+		}
+	}
+
+	return nil, false
+}
+
+// parseAuthParams parses the comma separated name=value pairs of a challenge,
+// values may be quoted
+func parseAuthParams(text string) map[string]string {
+	params := map[string]string{}
+	for {
+		text = strings.TrimLeft(text, " ,")
+		equals := strings.Index(text, "=")
+		if equals < 0 {
+			return params
+		}
+		name := strings.ToLower(strings.TrimSpace(text[:equals]))
+		text = strings.TrimLeft(text[equals+1:], " ")
+
+		var value strings.Builder
+		if strings.HasPrefix(text, "\"") {
+			i := 1
+			for ; i < len(text) && text[i] != '"'; i++ {
+				if text[i] == '\\' && i+1 < len(text) {
+					i++
+				}
+				value.WriteByte(text[i])
+			}
+			if i < len(text) {
+				i++
+			}
+			text = text[i:]
+		} else {
+			end := strings.Index(text, ",")
+			if end < 0 {
+				end = len(text)
+			}
+			value.WriteString(strings.TrimSpace(text[:end]))
+			text = text[end:]
+		}
+		params[name] = value.String()
+	}
+}
+
+func newCnonce() string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	return hex.EncodeToString(nonce)
+}
+
+// digestAuthorization computes the Authorization header answering a digest
+// challenge as described in RFC 7616
+func digestAuthorization(challenge map[string]string, method string, uri string, username string, password string, cnonce string) (string, error) {
+	algorithm := challenge["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("Unsupported digest algorithm: %s", algorithm)
+	}
+	digest := func(parts ...string) string {
+		h := newHash()
+		h.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	realm, nonce := challenge["realm"], challenge["nonce"]
+	ha1 := digest(username, realm, password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = digest(ha1, nonce, cnonce)
+	}
+	ha2 := digest(method, uri)
+
+	qop := ""
+	for _, offered := range strings.Split(challenge["qop"], ",") {
+		if strings.TrimSpace(offered) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	const nc = "00000001"
+	response := digest(ha1, nonce, ha2)
+	if qop != "" {
+		response = digest(ha1, nonce, nc, cnonce, qop, ha2)
+	}
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, response="%s"`,
+		username, realm, nonce, uri, algorithm, response)
+	if qop != "" {
+		authorization += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+	if opaque, found := challenge["opaque"]; found {
+		authorization += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+
+	return authorization, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthValidate(t *testing.T) {
+	assert.NoError(t, authOptions{}.validate())
+	assert.NoError(t, authOptions{Type: "Basic", Username: "user"}.validate())
+	assert.EqualError(t, authOptions{Type: authDigest}.validate(), "Please specify a username for digest authentication")
+	assert.EqualError(t, authOptions{Type: authBearer}.validate(), "Please specify a token for bearer authentication")
+	assert.EqualError(t, authOptions{Type: "ntlm"}.validate(), "Unknown authentication: ntlm")
+	assert.EqualError(t, clientTLSOptions{CertFile: "cert.pem"}.validate(), "Please specify both certFile and keyFile")
+}
+
+func TestParseAuthParams(t *testing.T) {
+	params := parseAuthParams(`realm="test, \"realm\"", qop="auth,auth-int", nonce=abc, algorithm=MD5`)
+	assert.Equal(t, map[string]string{"realm": `test, "realm"`, "qop": "auth,auth-int", "nonce": "abc", "algorithm": "MD5"}, params)
+}
+
+func TestDigestAuthorization(t *testing.T) {
+	// The example of RFC 2617
+	challenge := map[string]string{"realm": "testrealm@host.com", "qop": "auth,auth-int", "nonce": "dcd98b7102dd2f0e8b11d0f600bfb0c093", "opaque": "5ccc069c403ebaf9f0171e9517f40e41"}
+	authorization, err := digestAuthorization(challenge, "GET", "/dir/index.html", "Mufasa", "Circle Of Life", "0a4f113b")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, `Digest username="Mufasa", realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", uri="/dir/index.html", algorithm=MD5, `+
+		`response="6629fae49393a05397450978507c4ef1", qop=auth, nc=00000001, cnonce="0a4f113b", opaque="5ccc069c403ebaf9f0171e9517f40e41"`, authorization)
+
+	challenge["algorithm"] = "SHA-512"
+	_, err = digestAuthorization(challenge, "GET", "/", "Mufasa", "Circle Of Life", "0a4f113b")
+	assert.EqualError(t, err, "Unsupported digest algorithm: SHA-512")
+}
+
+func TestGetContentBasicAndBearerAuth(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		username, password, ok := req.BasicAuth()
+		if ok && username == "user" && password == "secret" || req.Header.Get("Authorization") == "Bearer token" {
+			res.Write([]byte("welcome"))
+			return
+		}
+		res.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer func() { testServer.Close() }()
+
+	setenv(t, "WD_TEST_PASSWORD", "secret")
+	response, _, err := getContent(testServer.URL, fetchOptions{Auth: authOptions{Type: authBasic, Username: "user", Password: "${WD_TEST_PASSWORD}"}}, nil)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "welcome", string(response))
+
+	response, _, err = getContent(testServer.URL, fetchOptions{Auth: authOptions{Type: authBearer, Token: "token"}}, nil)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "welcome", string(response))
+
+	_, _, err = getContent(testServer.URL, fetchOptions{Auth: authOptions{Type: authBasic, Username: "user", Password: "wrong"}}, nil)
+	assert.EqualError(t, err, "Incorrect HTTP Status Code: 401 Unauthorized")
+}
+
+func TestGetContentDigestAuth(t *testing.T) {
+	md5Hex := func(text string) string {
+		sum := md5.Sum([]byte(text))
+		return hex.EncodeToString(sum[:])
+	}
+
+	var requests int
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requests++
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err, "Expected no error")
+		assert.Equal(t, "q=shoes", string(body))
+
+		challenge, found := digestChallenge(req.Header.Values("Authorization"))
+		if found {
+			ha1 := md5Hex("user:shop:secret")
+			ha2 := md5Hex(req.Method + ":" + challenge["uri"])
+			expected := md5Hex(fmt.Sprintf("%s:nonce:%s:%s:auth:%s", ha1, challenge["nc"], challenge["cnonce"], ha2))
+			if challenge["response"] == expected && challenge["opaque"] == "opaque" {
+				res.Write([]byte("welcome"))
+				return
+			}
+		}
+		res.Header().Set("WWW-Authenticate", `Digest realm="shop", qop="auth", nonce="nonce", opaque="opaque"`)
+		res.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer func() { testServer.Close() }()
+
+	options := fetchOptions{Body: "q=shoes", Auth: authOptions{Type: authDigest, Username: "user", Password: "secret"}}
+	response, _, err := getContent(testServer.URL+"/search?page=2", options, nil)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "welcome", string(response))
+	assert.Equal(t, 2, requests)
+
+	options.Auth.Password = "wrong"
+	_, _, err = getContent(testServer.URL, options, nil)
+	assert.EqualError(t, err, "Incorrect HTTP Status Code: 401 Unauthorized")
+}
+
+func TestGetContentClientCertificate(t *testing.T) {
+	cert, err := tls.LoadX509KeyPair("testdata/testdomain.com/cert.pem", "testdata/testdomain.com/key.pem")
+	require.NoError(t, err, "Expected no error")
+	ca, err := ioutil.ReadFile("testdata/cert.pem")
+	require.NoError(t, err, "Expected no error")
+	clientCAs := x509.NewCertPool()
+	require.True(t, clientCAs.AppendCertsFromPEM(ca))
+
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte("hello " + req.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	testServer.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	testServer.StartTLS()
+	defer func() { testServer.Close() }()
+
+	options := fetchOptions{TLS: clientTLSOptions{
+		CAFile:     "testdata/cert.pem",
+		CertFile:   "testdata/testdomain.com/cert.pem",
+		KeyFile:    "testdata/testdomain.com/key.pem",
+		ServerName: "testdomain.com",
+	}}
+	response, _, err := getContent(testServer.URL, options, nil)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "hello testdomain.com", string(response))
+
+	// Without the client certificate the server refuses the connection
+	options.TLS.CertFile, options.TLS.KeyFile = "", ""
+	_, _, err = getContent(testServer.URL, options, nil)
+	assert.Error(t, err)
+
+	// Without the CA the server certificate is not trusted
+	_, _, err = getContent(testServer.URL, fetchOptions{}, nil)
+	assert.Contains(t, err.Error(), "certificate")
+
+	_, _, err = getContent(testServer.URL, fetchOptions{TLS: clientTLSOptions{CAFile: "testdata/missing.pem"}}, nil)
+	assert.Contains(t, err.Error(), "Unable to read CA file")
+}
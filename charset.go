@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// byteOrderMark is the UTF-8 encoded byte order mark which is left in front
+// of the decoded body
+var byteOrderMark = []byte("\ufeff")
+
+// decodeCharset transcodes body to UTF-8. The charset is taken from a byte
+// order mark, the Content-Type header or a <meta> element, in that order,
+// and its name is returned.
+func decodeCharset(contentType string, body []byte) ([]byte, string, error) {
+	encoding, name, certain := charset.DetermineEncoding(body, contentType)
+	// Without any declaration windows-1252 is assumed, but text which is
+	// valid UTF-8 is much more likely to be UTF-8
+	if !certain && name == "windows-1252" && utf8.Valid(body) {
+		return bytes.TrimPrefix(body, byteOrderMark), "utf-8", nil
+	}
+
+	decoded, err := encoding.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, "", fmt.Errorf("Unable to decode %s Response: %s", name, err)
+	}
+
+	return bytes.TrimPrefix(decoded, byteOrderMark), name, nil
+}
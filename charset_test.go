@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeCharset(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		expected    string
+		charset     string
+	}{
+		{"text/html; charset=ISO-8859-1", "<p>Gr\xfc\xdfe</p>", "<p>Grüße</p>", "windows-1252"},
+		{"text/html", "<meta charset=\"windows-1252\"><p>10 \x80</p>", "<meta charset=\"windows-1252\"><p>10 €</p>", "windows-1252"},
+		{"text/html", "<meta http-equiv=\"Content-Type\" content=\"text/html; charset=iso-8859-15\"><p>\xa4</p>", "<meta http-equiv=\"Content-Type\" content=\"text/html; charset=iso-8859-15\"><p>€</p>", "iso-8859-15"},
+		{"text/html; charset=ISO-8859-1", "\xef\xbb\xbf<p>Grüße</p>", "<p>Grüße</p>", "utf-8"},
+		{"", "\xff\xfe<\x00p\x00>\x00", "<p>", "utf-16le"},
+		{"", "<p>Grüße</p>", "<p>Grüße</p>", "utf-8"},
+		{"", "<p>Gr\xfc\xdfe</p>", "<p>Grüße</p>", "windows-1252"},
+		{"application/json", "{\"name\": \"Grüße\"}", "{\"name\": \"Grüße\"}", "utf-8"},
+	}
+
+	for _, test := range tests {
+		body, name, err := decodeCharset(test.contentType, []byte(test.body))
+		require.NoError(t, err, test.body)
+		assert.Equal(t, test.expected, string(body), test.body)
+		assert.Equal(t, test.charset, name, test.body)
+	}
+}
+
+func TestCheckWatchStoresCharset(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/html; charset=ISO-8859-1")
+		res.Write([]byte("<p>Gr\xfc\xdfe</p>"))
+	}))
+	defer func() { testServer.Close() }()
+
+	response, name, err := getContent(testServer.URL, fetchOptions{}, nil)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "<p>Grüße</p>", string(response))
+	assert.Equal(t, "windows-1252", name)
+
+	db := openTestDB(t)
+	w := defaultWatch()
+	w.URL = testServer.URL
+	w.Notify = []notifierOptions{{Type: notifierWebhook, URL: testServer.URL}}
+	require.NoError(t, checkWatch(context.Background(), db, w))
+
+	var stored, storedCharset string
+	err = db.QueryRow("SELECT response, charset FROM responseData WHERE url = ?", w.URL).Scan(&stored, &storedCharset)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "<p>Grüße</p>", stored)
+	assert.Equal(t, "windows-1252", storedCharset)
+}
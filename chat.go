@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	notifierSlack      = "slack"
+	notifierMattermost = "mattermost"
+	notifierTeams      = "teams"
+
+	// Slack rejects section texts longer than slackTextLimit characters, the
+	// other limits keep messages readable well below what the platforms
+	// accept
+	slackTextLimit      = 3000
+	slackDiffLimit      = 2800
+	mattermostDiffLimit = 12000
+	teamsDiffLimit      = 12000
+
+	changeColor = "FF8000"
+)
+
+// truncateDiff shortens text to at most limit bytes, cut at a line boundary,
+// and appends how many lines were left out. A first line longer than limit
+// is cut and reported as truncated.
+func truncateDiff(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+
+	lines := strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+	var b strings.Builder
+	kept := 0
+	for _, line := range lines {
+		if b.Len()+len(line) > limit {
+			break
+		}
+		b.WriteString(line)
+		kept++
+	}
+
+	if kept == 0 {
+		// Even the first line is too long, cut it without splitting a rune
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		b.WriteString(text[:cut] + "\n... line truncated")
+		if len(lines) > 1 {
+			fmt.Fprintf(&b, ", %d more lines", len(lines)-1)
+		}
+		return b.String()
+	}
+
+	if !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "... %d more lines", len(lines)-kept)
+
+	return b.String()
+}
+
+// codeBlock wraps text in a markdown code block. Backtick fences inside the
+// diff are broken up so they cannot end the block early.
+func codeBlock(language string, text string) string {
+	return "```" + language + "\n" + strings.Replace(text, "```", "`\u200b``", -1) + "\n```"
+}
+
+func changeTitle(c change) string {
+	name := c.Watch
+	if name == "" {
+		name = c.URL
+	}
+
+	return "Change detected on " + name
+}
+
+func changeSummary(c change) string {
+	return fmt.Sprintf("+%d / -%d lines between %s and %s", c.Stats.Added, c.Stats.Removed, c.FromDate, c.ToDate)
+}
+
+// escapeSlack escapes the characters Slack's mrkdwn treats as control
+// sequences
+func escapeSlack(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+func slackPayload(c change, limit int) interface{} {
+	heading := fmt.Sprintf("*<%s|%s>*\n%s", escapeSlack(c.URL), escapeSlack(changeTitle(c)), escapeSlack(changeSummary(c)))
+
+	// The diff is cut before escaping so no entity is split. Escaping
+	// lengthens it, so it is cut shorter until the block fits.
+	text := changeText(c)
+	diff := codeBlock("", escapeSlack(truncateDiff(text, limit)))
+	for cut := limit; len(diff) > slackTextLimit && cut > 1; {
+		cut -= len(diff) - slackTextLimit
+		if cut < 1 {
+			cut = 1
+		}
+		diff = codeBlock("", escapeSlack(truncateDiff(text, cut)))
+	}
+
+	return slackMessage{
+		Text: changeTitle(c),
+		Blocks: []slackBlock{
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: heading}},
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: diff}},
+		},
+	}
+}
+
+type mattermostField struct {
+	Short bool   `json:"short"`
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type mattermostAttachment struct {
+	Fallback  string            `json:"fallback"`
+	Color     string            `json:"color"`
+	Title     string            `json:"title"`
+	TitleLink string            `json:"title_link"`
+	Text      string            `json:"text"`
+	Fields    []mattermostField `json:"fields"`
+}
+
+type mattermostMessage struct {
+	Text        string                 `json:"text"`
+	Attachments []mattermostAttachment `json:"attachments"`
+}
+
+func mattermostPayload(c change, limit int) interface{} {
+	return mattermostMessage{
+		Text: changeTitle(c),
+		Attachments: []mattermostAttachment{{
+			Fallback:  changeTitle(c) + ": " + changeSummary(c),
+			Color:     "#" + changeColor,
+			Title:     c.URL,
+			TitleLink: c.URL,
+			Text:      codeBlock("diff", truncateDiff(changeText(c), limit)),
+			Fields: []mattermostField{
+				{Short: true, Title: "Added", Value: strconv.Itoa(c.Stats.Added)},
+				{Short: true, Title: "Removed", Value: strconv.Itoa(c.Stats.Removed)},
+				{Short: true, Title: "Previous crawl", Value: c.FromDate},
+				{Short: true, Title: "Current crawl", Value: c.ToDate},
+			},
+		}},
+	}
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type teamsSection struct {
+	Facts []teamsFact `json:"facts"`
+	Text  string      `json:"text"`
+}
+
+type teamsTarget struct {
+	OS  string `json:"os"`
+	URI string `json:"uri"`
+}
+
+type teamsAction struct {
+	Type    string        `json:"@type"`
+	Name    string        `json:"name"`
+	Targets []teamsTarget `json:"targets"`
+}
+
+// teamsMessage is a legacy actionable MessageCard, which every Teams incoming
+// webhook accepts
+type teamsMessage struct {
+	Type            string         `json:"@type"`
+	Context         string         `json:"@context"`
+	Summary         string         `json:"summary"`
+	ThemeColor      string         `json:"themeColor"`
+	Title           string         `json:"title"`
+	Sections        []teamsSection `json:"sections"`
+	PotentialAction []teamsAction  `json:"potentialAction"`
+}
+
+func teamsPayload(c change, limit int) interface{} {
+	return teamsMessage{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		Summary:    changeTitle(c),
+		ThemeColor: changeColor,
+		Title:      changeTitle(c),
+		Sections: []teamsSection{{
+			Facts: []teamsFact{
+				{Name: "Added", Value: strconv.Itoa(c.Stats.Added)},
+				{Name: "Removed", Value: strconv.Itoa(c.Stats.Removed)},
+				{Name: "Previous crawl", Value: c.FromDate},
+				{Name: "Current crawl", Value: c.ToDate},
+			},
+			Text: "<pre>" + html.EscapeString(truncateDiff(changeText(c), limit)) + "</pre>",
+		}},
+		PotentialAction: []teamsAction{{
+			Type:    "OpenUri",
+			Name:    "Open page",
+			Targets: []teamsTarget{{OS: "default", URI: c.URL}},
+		}},
+	}
+}
+
+// chatNotifier posts changes to the incoming webhook of a chat tool
+type chatNotifier struct {
+	name    string
+	url     string
+	limit   int
+	payload func(c change, limit int) interface{}
+	retry   retryPolicy
+	client  *http.Client
+}
+
+func newChatNotifier(options notifierOptions) (notifier, error) {
+	n := &chatNotifier{
+		name:   options.Type,
+		url:    options.URL,
+		retry:  newRetryPolicy(options),
+		client: newNotifierClient(options),
+	}
+
+	switch options.Type {
+	case notifierSlack:
+		n.payload, n.limit = slackPayload, slackDiffLimit
+	case notifierMattermost:
+		n.payload, n.limit = mattermostPayload, mattermostDiffLimit
+	case notifierTeams:
+		n.payload, n.limit = teamsPayload, teamsDiffLimit
+	default:
+		return nil, fmt.Errorf("Unknown notifier type: %q", options.Type)
+	}
+
+	if options.MaxDiff > 0 {
+		n.limit = options.MaxDiff
+	}
+
+	if n.url == "" {
+		return nil, fmt.Errorf("Please specify the %s webhook URL", options.Type)
+	}
+
+	return n, nil
+}
+
+func (n *chatNotifier) Notify(ctx context.Context, c change) error {
+	body, err := json.Marshal(n.payload(c, n.limit))
+	if err != nil {
+		return err
+	}
+
+	err = postJSON(ctx, n.client, n.url, body, nil, n.retry)
+	if err != nil {
+		return fmt.Errorf("Unable to notify %s: %s", n.name, err)
+	}
+
+	return nil
+}
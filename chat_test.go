@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateDiff(t *testing.T) {
+	assert.Equal(t, "a\nb\n", truncateDiff("a\nb\n", 10))
+	assert.Equal(t, "line 1\nline 2\n... 2 more lines", truncateDiff("line 1\nline 2\nline 3\nline 4\n", 16))
+	assert.Equal(t, "line 1\n... 1 more lines", truncateDiff("line 1\nline 2", 10))
+
+	// A single overlong line is cut without splitting a multi-byte rune
+	assert.Equal(t, "a\n... line truncated, 1 more lines", truncateDiff("aäbcdef\nline 2\n", 2))
+	assert.Equal(t, "ää\n... line truncated", truncateDiff("äää", 4))
+	assert.Equal(t, "a\n... line truncated", truncateDiff("ab\n", 1))
+}
+
+func TestCodeBlock(t *testing.T) {
+	assert.Equal(t, "```diff\n-a\n+b\n```", codeBlock("diff", "-a\n+b"))
+	assert.Equal(t, "```\n`\u200b``\n```", codeBlock("", "```"))
+}
+
+func TestSlackPayload(t *testing.T) {
+	payload := slackPayload(webhookChange, slackDiffLimit).(slackMessage)
+
+	assert.Equal(t, "Change detected on test", payload.Text)
+	require.Len(t, payload.Blocks, 2)
+	assert.Equal(t, "*<https://www.test.com|Change detected on test>*\n+2 / -2 lines between 2021-03-01 10:00:00 and 2021-03-01 11:00:00", payload.Blocks[0].Text.Text)
+	assert.Equal(t, "mrkdwn", payload.Blocks[1].Text.Type)
+	assert.Contains(t, payload.Blocks[1].Text.Text, "-&lt;h1&gt;This is a heading&lt;/h1&gt;\n")
+	assert.True(t, strings.HasPrefix(payload.Blocks[1].Text.Text, "```\n--- Old\n"))
+
+	// Large diffs are truncated below Slack's limit for a section text
+	large := webhookChange
+	large.Text = strings.Repeat("+<p>added line</p>\n", 1000)
+	payload = slackPayload(large, slackDiffLimit).(slackMessage)
+	assert.True(t, len(payload.Blocks[1].Text.Text) <= slackTextLimit)
+	assert.Contains(t, payload.Blocks[1].Text.Text, "more lines\n```")
+
+	// The diff is cut before it is escaped, so no entity is split
+	large.Text = strings.Repeat("&", 5000)
+	payload = slackPayload(large, slackDiffLimit).(slackMessage)
+	text := payload.Blocks[1].Text.Text
+	assert.True(t, len(text) <= slackTextLimit)
+	assert.Equal(t, strings.Count(text, "&"), strings.Count(text, "&amp;"))
+	assert.Contains(t, text, "... line truncated\n```")
+}
+
+func TestMattermostPayload(t *testing.T) {
+	payload := mattermostPayload(webhookChange, mattermostDiffLimit).(mattermostMessage)
+
+	assert.Equal(t, "Change detected on test", payload.Text)
+	require.Len(t, payload.Attachments, 1)
+	attachment := payload.Attachments[0]
+	assert.Equal(t, "https://www.test.com", attachment.TitleLink)
+	assert.Equal(t, "#FF8000", attachment.Color)
+	assert.Equal(t, "```diff\n"+sendEmailDiff.text+"\n```", attachment.Text)
+	assert.Equal(t, mattermostField{Short: true, Title: "Added", Value: "2"}, attachment.Fields[0])
+	assert.Equal(t, mattermostField{Short: true, Title: "Current crawl", Value: "2021-03-01 11:00:00"}, attachment.Fields[3])
+}
+
+func TestTeamsPayload(t *testing.T) {
+	body, err := json.Marshal(teamsPayload(webhookChange, 60))
+	require.NoError(t, err, "Expected no error")
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, "MessageCard", payload["@type"])
+	assert.Equal(t, "https://schema.org/extensions", payload["@context"])
+	assert.Equal(t, "Change detected on test", payload["title"])
+
+	section := payload["sections"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "<pre>--- Old\n+++ Current\n@@ -5,8 +5,8 @@\n &lt;/head&gt;\n &lt;body&gt;\n \n... 7 more lines</pre>", section["text"])
+
+	action := payload["potentialAction"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "OpenUri", action["@type"])
+	assert.Equal(t, "https://www.test.com", action["targets"].([]interface{})[0].(map[string]interface{})["uri"])
+}
+
+func TestChatNotifier(t *testing.T) {
+	for _, notifierType := range []string{notifierSlack, notifierMattermost, notifierTeams} {
+		var received map[string]interface{}
+		testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+			body, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err, "Expected no error")
+			require.NoError(t, json.Unmarshal(body, &received))
+		}))
+
+		n, err := newChatNotifier(notifierOptions{Type: notifierType, URL: testServer.URL})
+		require.NoError(t, err, "Expected no error")
+		require.NoError(t, n.Notify(context.Background(), webhookChange))
+		assert.NotEmpty(t, received, notifierType)
+
+		testServer.Close()
+	}
+}
+
+func TestChatNotifierError(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(404)
+	}))
+	defer func() { testServer.Close() }()
+
+	n, err := newChatNotifier(notifierOptions{Type: notifierSlack, URL: testServer.URL})
+	require.NoError(t, err, "Expected no error")
+
+	err = n.Notify(context.Background(), webhookChange)
+	assert.Equal(t, "Unable to notify slack: Incorrect HTTP Status Code: 404 Not Found", err.Error())
+}
+
+func TestNewChatNotifier(t *testing.T) {
+	n, err := newChatNotifier(notifierOptions{Type: notifierTeams, URL: "https://teams.test.com", MaxDiff: 100})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, 100, n.(*chatNotifier).limit)
+
+	_, err = newChatNotifier(notifierOptions{Type: notifierMattermost})
+	assert.Equal(t, "Please specify the mattermost webhook URL", err.Error())
+
+	_, err = newChatNotifier(notifierOptions{Type: "irc", URL: "https://irc.test.com"})
+	assert.Equal(t, "Unknown notifier type: \"irc\"", err.Error())
+}
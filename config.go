@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"WD/difflib"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultDatabase = "data.sqlite"
+
+// config holds every watch handled by one run of the detector
+type config struct {
+	Database string
+	Workers  poolOptions
+	Defaults watch
+	Watches  []watch
+}
+
+// watch describes one URL to scan and whom to report its changes to
+type watch struct {
+	Name      string            `yaml:"name"`
+	URL       string            `yaml:"url"`
+	To        []string          `yaml:"to"`
+	From      string            `yaml:"from"`
+	SMTP      smtpOptions       `yaml:"smtp"`
+	Notify    []notifierOptions `yaml:"notify"`
+	Fetch     fetchOptions      `yaml:"fetch"`
+	Diff      diffOptions       `yaml:"diff"`
+	Extract   extractOptions    `yaml:"extract"`
+	Ignore    ignoreOptions     `yaml:"ignore"`
+	Normalize normalizeOptions  `yaml:"normalize"`
+	Schedule  scheduleOptions   `yaml:"schedule"`
+	Threshold thresholdOptions  `yaml:"threshold"`
+	Triggers  []triggerOptions  `yaml:"triggers"`
+	Value     valueOptions      `yaml:"value"`
+	// TriggersOnly notifies only when a trigger fires or a value condition
+	// is met, not about every change
+	TriggersOnly bool `yaml:"triggersOnly"`
+}
+
+const (
+	diffHTMLInline     = "inline"
+	diffHTMLSideBySide = "sideBySide"
+	diffHTMLUnified    = "unified"
+)
+
+type diffOptions struct {
+	Context int `yaml:"context"`
+	// HTML selects how the diff is rendered in HTML emails: inline (default),
+	// sideBySide or unified for the plain unified diff
+	HTML string `yaml:"html"`
+	// Algorithm selects how lines are matched up: sequenceMatcher (default),
+	// myers, patience or histogram
+	Algorithm string `yaml:"algorithm"`
+	// ignoreCase is set from the normalize options of the watch
+	ignoreCase bool
+}
+
+var diffAlgorithms = map[string]difflib.Algorithm{
+	"":                nil,
+	"sequenceMatcher": difflib.RatcliffObershelp,
+	"myers":           difflib.Myers,
+	"patience":        difflib.Patience,
+	"histogram":       difflib.Histogram,
+}
+
+// algorithm returns the algorithm matching up the lines, nil meaning
+// SequenceMatcher
+func (options diffOptions) algorithm() difflib.Algorithm {
+	algorithm := diffAlgorithms[options.Algorithm]
+	if options.ignoreCase {
+		if algorithm == nil {
+			algorithm = difflib.RatcliffObershelp
+		}
+		algorithm = foldCase{algorithm: algorithm}
+	}
+
+	return algorithm
+}
+
+func (options diffOptions) validate() error {
+	switch options.HTML {
+	case "", diffHTMLInline, diffHTMLSideBySide, diffHTMLUnified:
+	default:
+		return fmt.Errorf("Unknown HTML diff view: %s", options.HTML)
+	}
+
+	if _, ok := diffAlgorithms[options.Algorithm]; !ok {
+		return fmt.Errorf("Unknown diff algorithm: %s", options.Algorithm)
+	}
+
+	return nil
+}
+
+// configFile is the on-disk layout. Watches are kept as raw nodes so each of
+// them can be decoded on top of the defaults, which makes every key a watch
+// sets override the default and every key it omits inherit it.
+type configFile struct {
+	Database string      `yaml:"database"`
+	Workers  poolOptions `yaml:"workers"`
+	Defaults yaml.Node   `yaml:"defaults"`
+	Watches  []yaml.Node `yaml:"watches"`
+}
+
+func defaultWatch() watch {
+	return watch{
+		SMTP:      defaultSMTPOptions(),
+		Fetch:     fetchOptions{Timeout: time.Second * 10},
+		Diff:      diffOptions{Context: 3},
+		Normalize: defaultNormalizeOptions(),
+	}
+}
+
+// clone returns a copy of w that shares no mutable state with it
+func (w watch) clone() watch {
+	w.To = append([]string(nil), w.To...)
+	w.Extract.Exclude = append([]string(nil), w.Extract.Exclude...)
+	w.Ignore.Patterns = append([]string(nil), w.Ignore.Patterns...)
+	w.Ignore.Lines = append([]string(nil), w.Ignore.Lines...)
+	w.Triggers = append([]triggerOptions(nil), w.Triggers...)
+	w.Value.Conditions = append([]string(nil), w.Value.Conditions...)
+	w.Fetch.Headers = copyStringMap(w.Fetch.Headers)
+	w.Fetch.Form = copyStringMap(w.Fetch.Form)
+	w.Fetch.Steps = append([]stepOptions(nil), w.Fetch.Steps...)
+	for i := range w.Fetch.Steps {
+		w.Fetch.Steps[i].Headers = copyStringMap(w.Fetch.Steps[i].Headers)
+		w.Fetch.Steps[i].Form = copyStringMap(w.Fetch.Steps[i].Form)
+	}
+	w.Notify = append([]notifierOptions(nil), w.Notify...)
+	for i := range w.Notify {
+		w.Notify[i].To = append([]string(nil), w.Notify[i].To...)
+		w.Notify[i].Headers = copyStringMap(w.Notify[i].Headers)
+	}
+
+	return w
+}
+
+// copyStringMap copies m, so decoding a watch onto the defaults does not add
+// keys to them
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	copied := make(map[string]string, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+
+	return copied
+}
+
+// diffOptions returns the diff options of the watch, which also depend on
+// its normalization
+func (w watch) diffOptions() diffOptions {
+	options := w.Diff
+	options.ignoreCase = w.Normalize.IgnoreCase
+
+	return options
+}
+
+func (w watch) validate() error {
+	if w.URL == "" {
+		return fmt.Errorf("Please specify an URL to scan")
+	}
+
+	_, err := w.notifiers()
+	if err != nil {
+		return err
+	}
+
+	err = w.Fetch.validate()
+	if err != nil {
+		return err
+	}
+
+	err = w.Extract.validate()
+	if err != nil {
+		return err
+	}
+
+	err = w.Ignore.validate()
+	if err != nil {
+		return err
+	}
+
+	err = w.Diff.validate()
+	if err != nil {
+		return err
+	}
+
+	err = w.Threshold.validate()
+	if err != nil {
+		return err
+	}
+
+	for i, t := range w.Triggers {
+		err = t.validate()
+		if err != nil {
+			return fmt.Errorf("Trigger %d: %s", i+1, err)
+		}
+	}
+
+	err = w.Value.validate()
+	if err != nil {
+		return err
+	}
+
+	if w.TriggersOnly && len(w.Triggers) == 0 && len(w.Value.Conditions) == 0 {
+		return fmt.Errorf("Please specify triggers to use triggersOnly")
+	}
+
+	return w.SMTP.validate()
+}
+
+func loadConfig(path string) (config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config{}, fmt.Errorf("Unable to read config: %s", err)
+	}
+
+	return parseConfig(data)
+}
+
+func parseConfig(data []byte) (config, error) {
+	file := configFile{Workers: defaultPoolOptions()}
+	err := yaml.Unmarshal(data, &file)
+	if err != nil {
+		return config{}, fmt.Errorf("Unable to parse config: %s", err)
+	}
+
+	cfg := config{Database: file.Database, Workers: file.Workers, Defaults: defaultWatch()}
+	if cfg.Database == "" {
+		cfg.Database = defaultDatabase
+	}
+
+	if !file.Defaults.IsZero() {
+		err = file.Defaults.Decode(&cfg.Defaults)
+		if err != nil {
+			return config{}, fmt.Errorf("Unable to parse defaults: %s", err)
+		}
+	}
+
+	if len(file.Watches) == 0 {
+		return config{}, fmt.Errorf("No watches configured")
+	}
+
+	// The crawls, history, values and cookies are stored by URL
+	watched := map[string]int{}
+	for i, node := range file.Watches {
+		w := cfg.Defaults.clone()
+		err = node.Decode(&w)
+		if err != nil {
+			return config{}, fmt.Errorf("Unable to parse watch %d: %s", i+1, err)
+		}
+
+		if w.Name == "" {
+			w.Name = w.URL
+		}
+
+		err = w.validate()
+		if err != nil {
+			return config{}, fmt.Errorf("Invalid watch %d: %s", i+1, err)
+		}
+
+		if first, found := watched[w.URL]; found {
+			return config{}, fmt.Errorf("Invalid watch %d: URL %s is already watched by watch %d", i+1, w.URL, first)
+		}
+		watched[w.URL] = i + 1
+
+		cfg.Watches = append(cfg.Watches, w)
+	}
+
+	return cfg, nil
+}
+
+// singleWatchConfig builds the configuration used when the detector is run
+// with -url, -to, -from and -tlsHost instead of a config file
+func singleWatchConfig(scanUrl string, toEmail string, fromEmail string, tlsHost string) (config, error) {
+	if scanUrl == "" {
+		return config{}, fmt.Errorf("Please specify an URL to scan")
+	}
+
+	if toEmail == "" {
+		return config{}, fmt.Errorf("Please specify a Report email")
+	}
+
+	if fromEmail == "" {
+		return config{}, fmt.Errorf("Please specify a Sender email")
+	}
+
+	if tlsHost == "" {
+		return config{}, fmt.Errorf("Please specify the TLS SMTP Domain")
+	}
+
+	w := defaultWatch()
+	w.Name = scanUrl
+	w.URL = scanUrl
+	w.To = []string{toEmail}
+	w.From = fromEmail
+	w.SMTP.TLSHost = tlsHost
+
+	return config{Database: defaultDatabase, Workers: defaultPoolOptions(), Defaults: defaultWatch(), Watches: []watch{w}}, nil
+}
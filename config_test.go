@@ -0,0 +1,200 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var configYAML = []byte(`
+database: watches.sqlite
+defaults:
+  from: from@test.com
+  to: [team@test.com]
+  smtp:
+    host: mail.test.com
+    tlsHost: testdomain.com
+  fetch:
+    timeout: 30s
+watches:
+  - url: https://www.test.com
+  - name: shop
+    url: https://shop.test.com
+    to: [shop@test.com, sales@test.com]
+    notify:
+      - type: email
+      - type: email
+        to: [boss@test.com]
+    smtp:
+      port: 465
+      encryption: tls
+    fetch:
+      timeout: 5s
+    diff:
+      context: 1
+    normalize:
+      ignoreCase: true
+`)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(configYAML)
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, "watches.sqlite", cfg.Database)
+	require.Len(t, cfg.Watches, 2)
+
+	assert.Equal(t, watch{
+		Name:      "https://www.test.com",
+		URL:       "https://www.test.com",
+		To:        []string{"team@test.com"},
+		From:      "from@test.com",
+		SMTP:      smtpOptions{Host: "mail.test.com", Port: 587, Encryption: encryptionSTARTTLS, TLSHost: "testdomain.com"},
+		Fetch:     fetchOptions{Timeout: time.Second * 30},
+		Diff:      diffOptions{Context: 3},
+		Normalize: defaultNormalizeOptions(),
+	}, cfg.Watches[0])
+
+	assert.Equal(t, watch{
+		Name:      "shop",
+		URL:       "https://shop.test.com",
+		To:        []string{"shop@test.com", "sales@test.com"},
+		From:      "from@test.com",
+		SMTP:      smtpOptions{Host: "mail.test.com", Port: 465, Encryption: encryptionTLS, TLSHost: "testdomain.com"},
+		Notify:    []notifierOptions{{Type: notifierEmail}, {Type: notifierEmail, To: []string{"boss@test.com"}}},
+		Fetch:     fetchOptions{Timeout: time.Second * 5},
+		Diff:      diffOptions{Context: 1},
+		Normalize: normalizeOptions{Trim: true, IgnoreCase: true, LineEndings: true},
+	}, cfg.Watches[1])
+
+	// Overrides must not leak back into the defaults
+	assert.Equal(t, []string{"team@test.com"}, cfg.Defaults.To)
+}
+
+func TestParseConfigDefaultDatabase(t *testing.T) {
+	cfg, err := parseConfig([]byte("watches:\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com}\n"))
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, defaultDatabase, cfg.Database)
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	_, err := parseConfig([]byte("watches: ["))
+	assert.Contains(t, err.Error(), "Unable to parse config")
+
+	_, err = parseConfig([]byte("database: test.sqlite\n"))
+	assert.Equal(t, "No watches configured", err.Error())
+
+	_, err = parseConfig([]byte("watches:\n  - url: https://www.test.com\n"))
+	assert.Equal(t, "Invalid watch 1: Notifier 1: Please specify a Report email", err.Error())
+
+	_, err = parseConfig([]byte("watches:\n  - {url: https://www.test.com, notify: [{type: pager}]}\n"))
+	assert.Equal(t, "Invalid watch 1: Notifier 1: Unknown notifier type: \"pager\"", err.Error())
+
+	_, err = parseConfig([]byte("watches:\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com, smtp: {encryption: ssl}}\n"))
+	assert.Equal(t, "Invalid watch 1: Unknown SMTP encryption: ssl", err.Error())
+
+	_, err = parseConfig([]byte("watches:\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com, ignore: {patterns: [\"(\"]}}\n"))
+	assert.Contains(t, err.Error(), "Invalid watch 1: Invalid ignore pattern")
+
+	_, err = parseConfig([]byte("watches:\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com, diff: {html: fancy}}\n"))
+	assert.Equal(t, "Invalid watch 1: Unknown HTML diff view: fancy", err.Error())
+
+	_, err = parseConfig([]byte("watches:\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com, diff: {algorithm: minimal}}\n"))
+	assert.Equal(t, "Invalid watch 1: Unknown diff algorithm: minimal", err.Error())
+
+	_, err = parseConfig([]byte("watches:\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com, threshold: {maxRatio: 2}}\n"))
+	assert.Equal(t, "Invalid watch 1: Threshold maxRatio must be between 0 and 1", err.Error())
+
+	_, err = parseConfig([]byte("watches:\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com, triggers: [{pattern: Sold out, on: vanish}]}\n"))
+	assert.Equal(t, "Invalid watch 1: Trigger 1: Unknown trigger event: vanish", err.Error())
+
+	_, err = parseConfig([]byte("watches:\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com, triggersOnly: true}\n"))
+	assert.Equal(t, "Invalid watch 1: Please specify triggers to use triggersOnly", err.Error())
+
+	_, err = parseConfig([]byte("watches:\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com, value: {conditions: [cheaper]}}\n"))
+	assert.Equal(t, "Invalid watch 1: Invalid value condition \"cheaper\"", err.Error())
+
+	_, err = parseConfig([]byte("watches:\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com}\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com, extract: {selector: p}}\n"))
+	assert.Equal(t, "Invalid watch 2: URL https://www.test.com is already watched by watch 1", err.Error())
+
+	_, err = parseConfig([]byte("watches:\n  - fetch: {timeout: soon}\n"))
+	assert.Contains(t, err.Error(), "Unable to parse watch 1")
+}
+
+func TestLoadConfigError(t *testing.T) {
+	_, err := loadConfig("testdata/missing.yaml")
+	assert.Contains(t, err.Error(), "Unable to read config")
+}
+
+func TestSingleWatchConfig(t *testing.T) {
+	cfg, err := singleWatchConfig("https://www.test.com", "to@test.com", "from@test.com", "testdomain.com")
+	require.NoError(t, err, "Expected no error")
+	require.Len(t, cfg.Watches, 1)
+	assert.Equal(t, []string{"to@test.com"}, cfg.Watches[0].To)
+	assert.Equal(t, 3, cfg.Watches[0].Diff.Context)
+
+	_, err = singleWatchConfig("", "to@test.com", "from@test.com", "testdomain.com")
+	assert.Equal(t, "Please specify an URL to scan", err.Error())
+
+	_, err = singleWatchConfig("https://www.test.com", "", "from@test.com", "testdomain.com")
+	assert.Equal(t, "Please specify a Report email", err.Error())
+
+	_, err = singleWatchConfig("https://www.test.com", "to@test.com", "", "testdomain.com")
+	assert.Equal(t, "Please specify a Sender email", err.Error())
+
+	_, err = singleWatchConfig("https://www.test.com", "to@test.com", "from@test.com", "")
+	assert.Equal(t, "Please specify the TLS SMTP Domain", err.Error())
+}
+
+func TestParseConfigFetchOptions(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+defaults:
+  to: [to@test.com]
+  from: from@test.com
+  fetch:
+    userAgent: WD/1.0
+    headers: {Authorization: "Bearer ${TOKEN}"}
+watches:
+  - url: https://www.test.com
+  - url: https://api.test.com/search
+    fetch:
+      method: POST
+      headers: {Accept: application/json}
+      json: {query: shoes, page: 1}
+`))
+	require.NoError(t, err, "Expected no error")
+	require.Len(t, cfg.Watches, 2)
+
+	assert.Equal(t, map[string]string{"Authorization": "Bearer ${TOKEN}"}, cfg.Watches[0].Fetch.Headers)
+	assert.Equal(t, "WD/1.0", cfg.Watches[1].Fetch.UserAgent)
+	assert.Equal(t, "POST", cfg.Watches[1].Fetch.Method)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer ${TOKEN}", "Accept": "application/json"}, cfg.Watches[1].Fetch.Headers)
+	assert.Equal(t, map[string]interface{}{"query": "shoes", "page": 1}, cfg.Watches[1].Fetch.JSON)
+
+	_, err = parseConfig([]byte("watches:\n  - {url: https://www.test.com, to: [to@test.com], from: from@test.com, fetch: {body: a, form: {q: b}}}\n"))
+	assert.EqualError(t, err, "Invalid watch 1: Please specify only one of body, form and json")
+}
+
+func TestParseConfigSteps(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+watches:
+  - url: https://shop.test.com/account
+    to: [to@test.com]
+    from: from@test.com
+    fetch:
+      steps:
+        - url: https://shop.test.com/login
+          extract:
+            csrf: {selector: "input[name=csrf]", attribute: value}
+        - url: https://shop.test.com/login
+          form: {user: me, password: "${SHOP_PASSWORD}", csrf: "${csrf}"}
+        - {}
+`))
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, []stepOptions{
+		{URL: "https://shop.test.com/login", Extract: map[string]variableOptions{"csrf": {Selector: "input[name=csrf]", Attribute: "value"}}},
+		{URL: "https://shop.test.com/login", Form: map[string]string{"user": "me", "password": "${SHOP_PASSWORD}", "csrf": "${csrf}"}},
+		{},
+	}, cfg.Watches[0].Fetch.Steps)
+}
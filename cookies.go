@@ -0,0 +1,113 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// dbCookieJar keeps the cookies of a watch in the database, so sessions
+// survive between runs
+type dbCookieJar struct {
+	*cookiejar.Jar
+	db    *sql.DB
+	watch string
+}
+
+// newCookieJar loads the stored cookies of watch
+func newCookieJar(db *sql.DB, watch string) (*dbCookieJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT url, cookie FROM cookies WHERE watch = ? ORDER BY rowid", watch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rawUrl, rawCookie string
+		err = rows.Scan(&rawUrl, &rawCookie)
+		if err != nil {
+			return nil, err
+		}
+
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			return nil, err
+		}
+		// The stored cookies are Set-Cookie values, the jar drops those which
+		// expired in the meantime
+		jar.SetCookies(u, (&http.Response{Header: http.Header{"Set-Cookie": {rawCookie}}}).Cookies())
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return &dbCookieJar{Jar: jar, db: db, watch: watch}, nil
+}
+
+// SetCookies stores the cookies before handing them to the jar. A relative
+// Max-Age is turned into an expiry date, so it is still correct when the
+// cookie is loaded again.
+func (j *dbCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		stored := *cookie
+		if stored.MaxAge > 0 {
+			stored.Expires = now.Add(time.Duration(stored.MaxAge) * time.Second)
+			stored.MaxAge = 0
+		}
+		deleted := stored.MaxAge < 0 || !stored.Expires.IsZero() && stored.Expires.Before(now)
+
+		err := j.store(u, &stored, deleted)
+		if err != nil {
+			log.Printf("%s: Unable to store cookie %s: %s", j.watch, cookie.Name, err)
+		}
+	}
+}
+
+func (j *dbCookieJar) store(u *url.URL, cookie *http.Cookie, deleted bool) error {
+	tx, err := j.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM cookies WHERE watch = ? AND domain = ? AND path = ? AND name = ?", j.watch, cookieDomain(u, cookie), cookie.Path, cookie.Name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if !deleted {
+		_, err = tx.Exec("INSERT INTO cookies(watch, url, domain, path, name, cookie) values(?, ?, ?, ?, ?, ?)",
+			j.watch, u.String(), cookieDomain(u, cookie), cookie.Path, cookie.Name, cookie.String())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// cookieDomain is the domain a cookie is stored for, the host which set it
+// if it has no Domain attribute
+func cookieDomain(u *url.URL, cookie *http.Cookie) string {
+	if cookie.Domain != "" {
+		return cookie.Domain
+	}
+
+	return u.Hostname()
+}
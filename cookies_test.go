@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookieJarPersists(t *testing.T) {
+	db := openTestDB(t)
+	u, err := url.Parse("https://www.test.com/account")
+	require.NoError(t, err, "Expected no error")
+
+	jar, err := newCookieJar(db, "https://www.test.com")
+	require.NoError(t, err, "Expected no error")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "1", MaxAge: 3600},
+		{Name: "theme", Value: "dark", Path: "/"},
+		{Name: "old", Value: "x", Expires: time.Now().Add(-time.Hour)},
+	})
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "2", MaxAge: 3600}})
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM cookies").Scan(&count))
+	assert.Equal(t, 2, count)
+
+	loaded, err := newCookieJar(db, "https://www.test.com")
+	require.NoError(t, err, "Expected no error")
+	assert.ElementsMatch(t, []*http.Cookie{{Name: "session", Value: "2"}, {Name: "theme", Value: "dark"}}, loaded.Cookies(u))
+
+	// Other watches do not share the cookies
+	other, err := newCookieJar(db, "https://shop.test.com")
+	require.NoError(t, err, "Expected no error")
+	assert.Empty(t, other.Cookies(u))
+
+	loaded.SetCookies(u, []*http.Cookie{{Name: "session", MaxAge: -1}})
+	loaded, err = newCookieJar(db, "https://www.test.com")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, []*http.Cookie{{Name: "theme", Value: "dark"}}, loaded.Cookies(u))
+}
+
+func TestCheckWatchKeepsCookies(t *testing.T) {
+	var sessions []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		cookie, err := req.Cookie("session")
+		if err != nil {
+			http.SetCookie(res, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			sessions = append(sessions, "")
+		} else {
+			sessions = append(sessions, cookie.Value)
+		}
+		res.Write([]byte("<p>Hello</p>"))
+	}))
+	defer func() { testServer.Close() }()
+
+	db := openTestDB(t)
+	w := defaultWatch()
+	w.URL = testServer.URL
+	w.Fetch.Cookies = true
+	w.Notify = []notifierOptions{{Type: notifierWebhook, URL: testServer.URL}}
+
+	require.NoError(t, checkWatch(context.Background(), db, w))
+	require.NoError(t, checkWatch(context.Background(), db, w))
+	assert.Equal(t, []string{"", "abc"}, sessions)
+}
@@ -0,0 +1,431 @@
+package difflib
+
+// Algorithm computes how to turn a into b. The returned opcodes follow the
+// conventions of SequenceMatcher.GetOpCodes(), so every algorithm can be used
+// to generate unified, context and HTML diffs.
+type Algorithm interface {
+	OpCodes(a, b []string) []OpCode
+}
+
+// The available algorithms. A nil Algorithm means RatcliffObershelp.
+var (
+	// RatcliffObershelp uses SequenceMatcher with its automatic junk
+	// heuristic, which ignores lines that are frequent in b, like "</div>"
+	// in HTML documents, when looking for matches
+	RatcliffObershelp Algorithm = matcherAlgorithm{}
+	// Myers finds a shortest edit script in O(ND) time and linear space,
+	// where N is the total length of the sequences and D the size of the
+	// edit script
+	Myers Algorithm = myersAlgorithm{}
+	// Patience aligns the sequences on lines which occur exactly once in
+	// both of them, which keeps moved blocks and reordered content readable
+	Patience Algorithm = patienceAlgorithm{}
+	// Histogram extends Patience to lines which occur more than once, and
+	// prefers the least frequent ones as anchors
+	Histogram Algorithm = histogramAlgorithm{}
+)
+
+type matcherAlgorithm struct{}
+
+func (matcherAlgorithm) OpCodes(a, b []string) []OpCode {
+	return NewMatcher(a, b).GetOpCodes()
+}
+
+// Convert matching blocks, terminated by the (len(a), len(b), 0) sentinel,
+// to opcodes.
+func matchingBlocksToOpCodes(matching []Match) []OpCode {
+	i, j := 0, 0
+	opCodes := make([]OpCode, 0, len(matching))
+	for _, m := range matching {
+		//  invariant:  we've pumped out correct diffs to change
+		//  a[:i] into b[:j], and the next matching block is
+		//  a[ai:ai+size] == b[bj:bj+size]. So we need to pump
+		//  out a diff to change a[i:ai] into b[j:bj], pump out
+		//  the matching block, and move (i,j) beyond the match
+		ai, bj, size := m.A, m.B, m.Size
+		tag := byte(0)
+		if i < ai && j < bj {
+			tag = 'r'
+		} else if i < ai {
+			tag = 'd'
+		} else if j < bj {
+			tag = 'i'
+		}
+		if tag > 0 {
+			opCodes = append(opCodes, OpCode{tag, i, ai, j, bj})
+		}
+		i, j = ai+size, bj+size
+		// the list of matching blocks is terminated by a
+		// sentinel with size 0
+		if size > 0 {
+			opCodes = append(opCodes, OpCode{'e', ai, i, bj, j})
+		}
+	}
+	return opCodes
+}
+
+// lineMatcher collects the matching lines found by the algorithms working
+// on interned lines
+type lineMatcher struct {
+	a, b    []int
+	blocks  []Match
+	pending Match
+}
+
+func newLineMatcher(a, b []string) *lineMatcher {
+	ids := map[string]int{}
+	intern := func(lines []string) []int {
+		result := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			result[i] = id
+		}
+		return result
+	}
+	return &lineMatcher{a: intern(a), b: intern(b)}
+}
+
+// match records that a[i:i+size] == b[j:j+size]. Matches have to be recorded
+// in order.
+func (m *lineMatcher) match(i, j, size int) {
+	if size == 0 {
+		return
+	}
+	p := &m.pending
+	if p.Size > 0 && p.A+p.Size == i && p.B+p.Size == j {
+		p.Size += size
+		return
+	}
+	if p.Size > 0 {
+		m.blocks = append(m.blocks, *p)
+	}
+	*p = Match{i, j, size}
+}
+
+func (m *lineMatcher) opCodes() []OpCode {
+	if m.pending.Size > 0 {
+		m.blocks = append(m.blocks, m.pending)
+	}
+	m.blocks = append(m.blocks, Match{len(m.a), len(m.b), 0})
+	return matchingBlocksToOpCodes(m.blocks)
+}
+
+// trim matches the common prefix and returns the bounds of the region
+// without the common prefix and suffix, and the length of that suffix
+func (m *lineMatcher) trim(alo, ahi, blo, bhi int) (int, int, int, int, int) {
+	prefix := 0
+	for alo+prefix < ahi && blo+prefix < bhi && m.a[alo+prefix] == m.b[blo+prefix] {
+		prefix++
+	}
+	m.match(alo, blo, prefix)
+	alo, blo = alo+prefix, blo+prefix
+
+	suffix := 0
+	for alo < ahi-suffix && blo < bhi-suffix && m.a[ahi-suffix-1] == m.b[bhi-suffix-1] {
+		suffix++
+	}
+	return alo, ahi - suffix, blo, bhi - suffix, suffix
+}
+
+type myersAlgorithm struct{}
+
+func (myersAlgorithm) OpCodes(a, b []string) []OpCode {
+	m := newLineMatcher(a, b)
+	m.myers(0, len(a), 0, len(b))
+	return m.opCodes()
+}
+
+// myers matches a[alo:ahi] and b[blo:bhi] by recursively splitting them at
+// the middle snake of a shortest edit script, as described in "An O(ND)
+// Difference Algorithm and Its Variations" by Eugene W. Myers
+func (m *lineMatcher) myers(alo, ahi, blo, bhi int) {
+	alo, ahi, blo, bhi, suffix := m.trim(alo, ahi, blo, bhi)
+	if alo < ahi && blo < bhi {
+		x, y, u, v := m.middleSnake(alo, ahi, blo, bhi)
+		m.myers(alo, x, blo, y)
+		m.match(x, y, u-x)
+		m.myers(u, ahi, v, bhi)
+	}
+	m.match(ahi, bhi, suffix)
+}
+
+// middleSnake returns the start (x, y) and end (u, v) of the middle snake of
+// a shortest edit script turning a[alo:ahi] into b[blo:bhi]
+func (m *lineMatcher) middleSnake(alo, ahi, blo, bhi int) (int, int, int, int) {
+	n, l := ahi-alo, bhi-blo
+	delta := n - l
+	odd := delta%2 != 0
+	limit := (n + l + 1) / 2
+	// forward[k] is the furthest x reached on diagonal k = x - y going
+	// forward, backward[k] the furthest distance from the end reached on
+	// diagonal k = (n - x) - (l - y) going backward
+	offset := limit + 1
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && forward[offset+k-1] < forward[offset+k+1] {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < l && m.a[alo+x] == m.b[blo+y] {
+				x, y = x+1, y+1
+			}
+			forward[offset+k] = x
+			if odd && delta-k >= -(d-1) && delta-k <= d-1 && x >= n-backward[offset+delta-k] {
+				return alo + x0, blo + y0, alo + x, blo + y
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && backward[offset+k-1] < backward[offset+k+1] {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < l && m.a[ahi-x-1] == m.b[bhi-y-1] {
+				x, y = x+1, y+1
+			}
+			backward[offset+k] = x
+			if !odd && delta-k >= -d && delta-k <= d && forward[offset+delta-k] >= n-x {
+				return ahi - x, bhi - y, ahi - x0, bhi - y0
+			}
+		}
+	}
+
+	// Not reached, a path of at most n + l edits always exists
+	return alo, blo, alo, blo
+}
+
+type patienceAlgorithm struct{}
+
+func (patienceAlgorithm) OpCodes(a, b []string) []OpCode {
+	m := newLineMatcher(a, b)
+	m.patience(0, len(a), 0, len(b))
+	return m.opCodes()
+}
+
+// patience matches the lines which are unique in both a[alo:ahi] and
+// b[blo:bhi], keeping the longest sequence of them which is in the same
+// order in both, and recurses between them. Regions without unique lines
+// are compared with Myers.
+func (m *lineMatcher) patience(alo, ahi, blo, bhi int) {
+	alo, ahi, blo, bhi, suffix := m.trim(alo, ahi, blo, bhi)
+	if alo < ahi && blo < bhi {
+		anchors := m.uniqueAnchors(alo, ahi, blo, bhi)
+		if len(anchors) == 0 {
+			m.myers(alo, ahi, blo, bhi)
+		} else {
+			i, j := alo, blo
+			for _, anchor := range anchors {
+				m.patience(i, anchor.A, j, anchor.B)
+				m.match(anchor.A, anchor.B, 1)
+				i, j = anchor.A+1, anchor.B+1
+			}
+			m.patience(i, ahi, j, bhi)
+		}
+	}
+	m.match(ahi, bhi, suffix)
+}
+
+// uniqueAnchors returns the longest increasing sequence of lines which
+// occur exactly once in both regions
+func (m *lineMatcher) uniqueAnchors(alo, ahi, blo, bhi int) []Match {
+	type occurrence struct {
+		countA, countB int
+		i, j           int
+	}
+	occurrences := map[int]*occurrence{}
+	for i := alo; i < ahi; i++ {
+		o := occurrences[m.a[i]]
+		if o == nil {
+			o = &occurrence{}
+			occurrences[m.a[i]] = o
+		}
+		o.countA++
+		o.i = i
+	}
+	for j := blo; j < bhi; j++ {
+		if o := occurrences[m.b[j]]; o != nil {
+			o.countB++
+			o.j = j
+		}
+	}
+
+	var candidates []Match
+	for i := alo; i < ahi; i++ {
+		o := occurrences[m.a[i]]
+		if o.countA == 1 && o.countB == 1 {
+			candidates = append(candidates, Match{A: o.i, B: o.j, Size: 1})
+		}
+	}
+
+	// Patience sorting: piles holds the index of the candidate on top of
+	// each pile, previous links every candidate to the top of the pile to
+	// its left when it was placed
+	var piles []int
+	previous := make([]int, len(candidates))
+	for c, candidate := range candidates {
+		pile, hi := 0, len(piles)
+		for pile < hi {
+			mid := (pile + hi) / 2
+			if candidates[piles[mid]].B < candidate.B {
+				pile = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		previous[c] = -1
+		if pile > 0 {
+			previous[c] = piles[pile-1]
+		}
+		if pile == len(piles) {
+			piles = append(piles, c)
+		} else {
+			piles[pile] = c
+		}
+	}
+	if len(piles) == 0 {
+		return nil
+	}
+
+	anchors := make([]Match, len(piles))
+	for c, k := piles[len(piles)-1], len(piles)-1; c >= 0; c, k = previous[c], k-1 {
+		anchors[k] = candidates[c]
+	}
+	return anchors
+}
+
+type histogramAlgorithm struct{}
+
+// Lines occurring more often than this in a region are not used as anchors
+const histogramMaxChain = 64
+
+func (histogramAlgorithm) OpCodes(a, b []string) []OpCode {
+	m := newLineMatcher(a, b)
+	m.histogram(0, len(a), 0, len(b))
+	return m.opCodes()
+}
+
+// histogram looks for the longest common region whose least frequent line
+// is as rare as possible in a[alo:ahi], matches it and recurses on both
+// sides, like the histogram diff of JGit and Git. Regions without usable
+// lines are compared with Myers.
+func (m *lineMatcher) histogram(alo, ahi, blo, bhi int) {
+	alo, ahi, blo, bhi, suffix := m.trim(alo, ahi, blo, bhi)
+	if alo < ahi && blo < bhi {
+		best, found := m.rarestRegion(alo, ahi, blo, bhi)
+		if !found {
+			m.myers(alo, ahi, blo, bhi)
+		} else {
+			m.histogram(alo, best.A, blo, best.B)
+			m.match(best.A, best.B, best.Size)
+			m.histogram(best.A+best.Size, ahi, best.B+best.Size, bhi)
+		}
+	}
+	m.match(ahi, bhi, suffix)
+}
+
+func (m *lineMatcher) rarestRegion(alo, ahi, blo, bhi int) (Match, bool) {
+	positions := map[int][]int{}
+	for i := alo; i < ahi; i++ {
+		positions[m.a[i]] = append(positions[m.a[i]], i)
+	}
+
+	var best Match
+	bestCount := histogramMaxChain + 1
+	for j := blo; j < bhi; {
+		next := j + 1
+		occurrences := positions[m.b[j]]
+		if len(occurrences) == 0 || len(occurrences) > bestCount {
+			j = next
+			continue
+		}
+
+		for _, i := range occurrences {
+			as, bs, ae, be := i, j, i+1, j+1
+			count := len(occurrences)
+			for as > alo && bs > blo && m.a[as-1] == m.b[bs-1] {
+				as, bs = as-1, bs-1
+				count = min(count, len(positions[m.a[as]]))
+			}
+			for ae < ahi && be < bhi && m.a[ae] == m.b[be] {
+				count = min(count, len(positions[m.a[ae]]))
+				ae, be = ae+1, be+1
+			}
+			if next < be {
+				next = be
+			}
+			if count < bestCount || count == bestCount && ae-as > best.Size {
+				best, bestCount = Match{as, bs, ae - as}, count
+			}
+		}
+		j = next
+	}
+
+	return best, best.Size > 0
+}
+
+// Isolate change clusters by eliminating ranges with no changes, like
+// SequenceMatcher.GetGroupedOpCodes() does for the opcodes of any Algorithm.
+func GroupOpCodes(codes []OpCode, n int) [][]OpCode {
+	if n < 0 {
+		n = 3
+	}
+	codes = append([]OpCode(nil), codes...)
+	if len(codes) == 0 {
+		codes = []OpCode{OpCode{'e', 0, 1, 0, 1}}
+	}
+	// Fixup leading and trailing groups if they show no changes.
+	if codes[0].Tag == 'e' {
+		c := codes[0]
+		i1, i2, j1, j2 := c.I1, c.I2, c.J1, c.J2
+		codes[0] = OpCode{c.Tag, max(i1, i2-n), i2, max(j1, j2-n), j2}
+	}
+	if codes[len(codes)-1].Tag == 'e' {
+		c := codes[len(codes)-1]
+		i1, i2, j1, j2 := c.I1, c.I2, c.J1, c.J2
+		codes[len(codes)-1] = OpCode{c.Tag, i1, min(i2, i1+n), j1, min(j2, j1+n)}
+	}
+	nn := n + n
+	groups := [][]OpCode{}
+	group := []OpCode{}
+	for _, c := range codes {
+		i1, i2, j1, j2 := c.I1, c.I2, c.J1, c.J2
+		// End the current group and start a new one whenever
+		// there is a large range with no changes.
+		if c.Tag == 'e' && i2-i1 > nn {
+			group = append(group, OpCode{c.Tag, i1, min(i2, i1+n),
+				j1, min(j2, j1+n)})
+			groups = append(groups, group)
+			group = []OpCode{}
+			i1, j1 = max(i1, i2-n), max(j1, j2-n)
+		}
+		group = append(group, OpCode{c.Tag, i1, i2, j1, j2})
+	}
+	if len(group) > 0 && !(len(group) == 1 && group[0].Tag == 'e') {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// groupedOpCodes groups the opcodes of algorithm, nil meaning
+// SequenceMatcher
+func groupedOpCodes(a, b []string, algorithm Algorithm, n int) [][]OpCode {
+	if algorithm == nil {
+		return NewMatcher(a, b).GetGroupedOpCodes(n)
+	}
+	return GroupOpCodes(algorithm.OpCodes(a, b), n)
+}
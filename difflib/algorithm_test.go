@@ -0,0 +1,235 @@
+package difflib
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var algorithms = map[string]Algorithm{
+	"RatcliffObershelp": RatcliffObershelp,
+	"Myers":             Myers,
+	"Patience":          Patience,
+	"Histogram":         Histogram,
+}
+
+// checkOpCodes verifies that codes turn a into b
+func checkOpCodes(t *testing.T, name string, a, b []string, codes []OpCode) {
+	i, j := 0, 0
+	var rebuilt []string
+	for _, c := range codes {
+		if c.I1 != i || c.J1 != j {
+			t.Fatalf("%s: opcode %v does not continue at %d, %d", name, c, i, j)
+		}
+		switch c.Tag {
+		case 'e':
+			if c.I2-c.I1 != c.J2-c.J1 || c.I1 == c.I2 {
+				t.Fatalf("%s: invalid equal opcode %v", name, c)
+			}
+			for k := 0; k < c.I2-c.I1; k++ {
+				if a[c.I1+k] != b[c.J1+k] {
+					t.Fatalf("%s: opcode %v matches different lines", name, c)
+				}
+			}
+		case 'r':
+			if c.I1 == c.I2 || c.J1 == c.J2 {
+				t.Fatalf("%s: invalid replace opcode %v", name, c)
+			}
+		case 'd':
+			if c.I1 == c.I2 || c.J1 != c.J2 {
+				t.Fatalf("%s: invalid delete opcode %v", name, c)
+			}
+		case 'i':
+			if c.I1 != c.I2 || c.J1 == c.J2 {
+				t.Fatalf("%s: invalid insert opcode %v", name, c)
+			}
+		}
+		rebuilt = append(rebuilt, b[c.J1:c.J2]...)
+		i, j = c.I2, c.J2
+	}
+	if i != len(a) || j != len(b) {
+		t.Fatalf("%s: opcodes end at %d, %d instead of %d, %d", name, i, j, len(a), len(b))
+	}
+	assertEqual(t, strings.Join(rebuilt, ""), strings.Join(b, ""))
+}
+
+// editDistance counts the lines deleted and inserted by codes
+func editDistance(codes []OpCode) int {
+	distance := 0
+	for _, c := range codes {
+		if c.Tag != 'e' {
+			distance += c.I2 - c.I1 + c.J2 - c.J1
+		}
+	}
+	return distance
+}
+
+// lcsDistance is the size of a shortest edit script, computed with the
+// quadratic dynamic programming solution
+func lcsDistance(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func randomLines(r *rand.Rand, count int, alphabet string) []string {
+	lines := make([]string, count)
+	for i := range lines {
+		lines[i] = string(alphabet[r.Intn(len(alphabet))])
+	}
+	return lines
+}
+
+func TestAlgorithmsRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for round := 0; round < 500; round++ {
+		a := randomLines(r, r.Intn(30), "abcde")
+		b := randomLines(r, r.Intn(30), "abcde")
+		for name, algorithm := range algorithms {
+			checkOpCodes(t, name, a, b, algorithm.OpCodes(a, b))
+		}
+		// Myers finds a shortest edit script
+		assertEqual(t, editDistance(Myers.OpCodes(a, b)), lcsDistance(a, b))
+	}
+}
+
+func TestAlgorithmsEmpty(t *testing.T) {
+	for name, algorithm := range algorithms {
+		assertEqual(t, len(algorithm.OpCodes(nil, nil)), 0)
+		checkOpCodes(t, name, nil, []string{"a"}, algorithm.OpCodes(nil, []string{"a"}))
+		checkOpCodes(t, name, []string{"a"}, nil, algorithm.OpCodes([]string{"a"}, nil))
+	}
+}
+
+func TestMyersKeepsPopularLines(t *testing.T) {
+	a, b := []string{"old\n"}, []string{"new\n"}
+	for i := 0; i < 300; i++ {
+		a = append(a, "</div>\n")
+		b = append(b, "</div>\n")
+	}
+
+	// The autojunk heuristic of SequenceMatcher ignores the frequent line
+	assertEqual(t, NewMatcher(a, b).GetOpCodes(), []OpCode{{'r', 0, 301, 0, 301}})
+
+	for _, algorithm := range []Algorithm{Myers, Patience, Histogram} {
+		assertEqual(t, algorithm.OpCodes(a, b), []OpCode{{'r', 0, 1, 0, 1}, {'e', 1, 301, 1, 301}})
+	}
+}
+
+func TestPatienceAlignsUniqueLines(t *testing.T) {
+	a := SplitLines("func a() {\n}\nfunc b() {\n}\n")
+	b := SplitLines("func b() {\n}\nfunc a() {\n}\n")
+	codes := Patience.OpCodes(a, b)
+	checkOpCodes(t, "Patience", a, b, codes)
+	assertEqual(t, editDistance(codes), 4)
+}
+
+func TestHistogramPrefersRareLines(t *testing.T) {
+	a := []string{"x", "}", "}", "unique", "}", "y"}
+	b := []string{"}", "unique", "}", "}", "z"}
+	codes := Histogram.OpCodes(a, b)
+	checkOpCodes(t, "Histogram", a, b, codes)
+	assertEqual(t, codes[1], OpCode{'e', 2, 5, 0, 3})
+}
+
+func TestGroupOpCodesDoesNotModifyInput(t *testing.T) {
+	codes := []OpCode{{'e', 0, 10, 0, 10}, {'i', 10, 10, 10, 11}}
+	groups := GroupOpCodes(codes, 2)
+	assertEqual(t, groups, [][]OpCode{{{'e', 8, 10, 8, 10}, {'i', 10, 10, 10, 11}}})
+	assertEqual(t, codes[0], OpCode{'e', 0, 10, 0, 10})
+}
+
+func ExampleUnifiedDiff_algorithm() {
+	diff := UnifiedDiff{
+		A:         SplitLines("<div>\n<b>10 €</b>\n</div>\n<div>\n<b>20 €</b>\n</div>"),
+		B:         SplitLines("<div>\n<b>20 €</b>\n</div>"),
+		FromFile:  "Old",
+		ToFile:    "Current",
+		Context:   1,
+		Algorithm: Histogram,
+	}
+	result, _ := GetUnifiedDiffString(diff)
+	fmt.Print(result)
+	// Output:
+	// --- Old
+	// +++ Current
+	// @@ -1,5 +1,2 @@
+	//  <div>
+	// -<b>10 €</b>
+	// -</div>
+	// -<div>
+	//  <b>20 €</b>
+}
+
+// largeHTML generates a shop page with count products
+func largeHTML(count int, changed int, moved bool) []string {
+	var b strings.Builder
+	b.WriteString("<html>\n<body>\n")
+	var products []string
+	for i := 0; i < count; i++ {
+		price := i
+		if changed > 0 && i%changed == 0 {
+			price++
+		}
+		products = append(products, fmt.Sprintf("<div class=\"product\">\n<h2>Product %d</h2>\n<p>\n<span>%d €</span>\n</p>\n</div>\n", i, price))
+	}
+	if moved {
+		products = append(products[count/2:], products[:count/2]...)
+	}
+	b.WriteString(strings.Join(products, ""))
+	b.WriteString("</body>\n</html>\n")
+	return SplitLines(b.String())
+}
+
+func benchmarkAlgorithm(b *testing.B, algorithm Algorithm, moved bool) {
+	old := largeHTML(2000, 0, false)
+	current := largeHTML(2000, 50, moved)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		algorithm.OpCodes(old, current)
+	}
+}
+
+func BenchmarkRatcliffObershelpLargeHTML(b *testing.B) {
+	benchmarkAlgorithm(b, RatcliffObershelp, false)
+}
+
+func BenchmarkMyersLargeHTML(b *testing.B) {
+	benchmarkAlgorithm(b, Myers, false)
+}
+
+func BenchmarkPatienceLargeHTML(b *testing.B) {
+	benchmarkAlgorithm(b, Patience, false)
+}
+
+func BenchmarkHistogramLargeHTML(b *testing.B) {
+	benchmarkAlgorithm(b, Histogram, false)
+}
+
+func BenchmarkRatcliffObershelpMovedHTML(b *testing.B) {
+	benchmarkAlgorithm(b, RatcliffObershelp, true)
+}
+
+func BenchmarkMyersMovedHTML(b *testing.B) {
+	benchmarkAlgorithm(b, Myers, true)
+}
+
+func BenchmarkPatienceMovedHTML(b *testing.B) {
+	benchmarkAlgorithm(b, Patience, true)
+}
+
+func BenchmarkHistogramMovedHTML(b *testing.B) {
+	benchmarkAlgorithm(b, Histogram, true)
+}
@@ -0,0 +1,322 @@
+package difflib
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Differ is a class for comparing sequences of lines of text, and producing
+// human-readable differences or deltas. Differ uses SequenceMatcher both to
+// compare sequences of lines, and to compare sequences of characters within
+// similar (near-matching) lines.
+//
+// Each line of a Differ delta begins with a two-letter code:
+//
+//	"- "    line unique to sequence 1
+//	"+ "    line unique to sequence 2
+//	"  "    line common to both sequences
+//	"? "    line not present in either input sequence
+//
+// Lines beginning with "? " attempt to guide the eye to intraline
+// differences, and were not present in either input sequence. These lines
+// can be confusing if the sequences contain tab characters.
+//
+// Note that Differ makes no claim to produce a *minimal* diff. To the
+// contrary, minimal diffs are often counter-intuitive, because they synch
+// up anywhere possible, sometimes accidental matches 100 pages apart.
+// Restricting synch points to contiguous matches preserves some notion of
+// locality, at the occasional cost of producing a longer diff.
+type Differ struct {
+	// LineJunk filters out lines without visible content, nil means no
+	// line is junk
+	LineJunk func(string) bool
+	// CharJunk filters out characters while comparing similar lines, nil
+	// means no character is junk
+	CharJunk func(string) bool
+}
+
+// Compare two sequences of lines; generate the resulting delta.
+//
+// Each sequence must contain individual single-line strings ending with
+// newlines. Such sequences can be obtained from SplitLines(). The delta
+// generated also consists of newline-terminated strings, ready to be
+// joined.
+func (d *Differ) Compare(a, b []string) []string {
+	var result []string
+	cruncher := NewMatcherWithJunk(a, b, true, d.LineJunk)
+	for _, c := range cruncher.GetOpCodes() {
+		switch c.Tag {
+		case 'r':
+			result = d.fancyReplace(result, a, c.I1, c.I2, b, c.J1, c.J2)
+		case 'd':
+			result = dump(result, "-", a, c.I1, c.I2)
+		case 'i':
+			result = dump(result, "+", b, c.J1, c.J2)
+		case 'e':
+			result = dump(result, " ", a, c.I1, c.I2)
+		}
+	}
+	return result
+}
+
+func dump(result []string, tag string, x []string, lo, hi int) []string {
+	for i := lo; i < hi; i++ {
+		result = append(result, tag+" "+x[i])
+	}
+	return result
+}
+
+func plainReplace(result []string, a []string, alo, ahi int, b []string, blo, bhi int) []string {
+	if bhi-blo < ahi-alo {
+		result = dump(result, "+", b, blo, bhi)
+		return dump(result, "-", a, alo, ahi)
+	}
+	result = dump(result, "-", a, alo, ahi)
+	return dump(result, "+", b, blo, bhi)
+}
+
+// When replacing one block of lines with another, search the blocks for
+// *similar* lines; the best-matching pair (if any) is used as a synch point,
+// and intraline difference marking is done on the similar pair. Lots of work,
+// but often worth it.
+func (d *Differ) fancyReplace(result []string, a []string, alo, ahi int, b []string, blo, bhi int) []string {
+	// don't synch up unless the lines have a similarity score of at
+	// least cutoff; bestRatio tracks the best score seen so far
+	bestRatio, cutoff := 0.74, 0.75
+	bestI, bestJ := 0, 0
+	cruncher := NewMatcherWithJunk(nil, nil, true, d.CharJunk)
+	// 1st indices of equal lines (if any)
+	eqi, eqj := -1, -1
+
+	// search for the pair that matches best without being identical
+	// (identical lines must be junk lines, & we don't want to synch up
+	// on junk -- unless we have to)
+	for j := blo; j < bhi; j++ {
+		bj := b[j]
+		cruncher.SetSeq2(SplitChars(bj))
+		for i := alo; i < ahi; i++ {
+			ai := a[i]
+			if ai == bj {
+				if eqi < 0 {
+					eqi, eqj = i, j
+				}
+				continue
+			}
+			cruncher.SetSeq1(SplitChars(ai))
+			// computing similarity is expensive, so use the quick
+			// upper bounds first -- have seen this speed up messy
+			// compares by a factor of 3.
+			if cruncher.RealQuickRatio() > bestRatio &&
+				cruncher.QuickRatio() > bestRatio &&
+				cruncher.Ratio() > bestRatio {
+				bestRatio, bestI, bestJ = cruncher.Ratio(), i, j
+			}
+		}
+	}
+
+	if bestRatio < cutoff {
+		// no non-identical "pretty close" pair
+		if eqi < 0 {
+			// no identical pair either -- treat it as a straight replace
+			return plainReplace(result, a, alo, ahi, b, blo, bhi)
+		}
+		// no close pair, but an identical pair -- synch up on that
+		bestI, bestJ, bestRatio = eqi, eqj, 1.0
+	} else {
+		// there's a close pair, so forget the identical pair (if any)
+		eqi = -1
+	}
+
+	// a[bestI] very similar to b[bestJ]; eqi is -1 iff they're not
+	// identical
+
+	// pump out diffs from before the synch point
+	result = d.fancyHelper(result, a, alo, bestI, b, blo, bestJ)
+
+	// do intraline marking on the synch pair
+	aelt, belt := a[bestI], b[bestJ]
+	if eqi < 0 {
+		// pump out a '-', '?', '+', '?' quad for the synched lines
+		var atags, btags strings.Builder
+		achars, bchars := SplitChars(aelt), SplitChars(belt)
+		cruncher.SetSeqs(achars, bchars)
+		for _, c := range cruncher.GetOpCodes() {
+			la, lb := c.I2-c.I1, c.J2-c.J1
+			switch c.Tag {
+			case 'r':
+				atags.WriteString(strings.Repeat("^", la))
+				btags.WriteString(strings.Repeat("^", lb))
+			case 'd':
+				atags.WriteString(strings.Repeat("-", la))
+			case 'i':
+				btags.WriteString(strings.Repeat("+", lb))
+			case 'e':
+				atags.WriteString(strings.Repeat(" ", la))
+				btags.WriteString(strings.Repeat(" ", lb))
+			}
+		}
+		result = qformat(result, aelt, belt, atags.String(), btags.String())
+	} else {
+		// the synch pair is identical
+		result = append(result, "  "+aelt)
+	}
+
+	// pump out diffs from after the synch point
+	return d.fancyHelper(result, a, bestI+1, ahi, b, bestJ+1, bhi)
+}
+
+func (d *Differ) fancyHelper(result []string, a []string, alo, ahi int, b []string, blo, bhi int) []string {
+	if alo < ahi {
+		if blo < bhi {
+			return d.fancyReplace(result, a, alo, ahi, b, blo, bhi)
+		}
+		return dump(result, "-", a, alo, ahi)
+	}
+	if blo < bhi {
+		return dump(result, "+", b, blo, bhi)
+	}
+	return result
+}
+
+// Format "?" output and deal with tabs.
+func qformat(result []string, aline, bline, atags, btags string) []string {
+	atags = strings.TrimRightFunc(keepOriginalWhitespace(aline, atags), unicode.IsSpace)
+	btags = strings.TrimRightFunc(keepOriginalWhitespace(bline, btags), unicode.IsSpace)
+
+	result = append(result, "- "+aline)
+	if atags != "" {
+		result = append(result, "? "+atags+"\n")
+	}
+	result = append(result, "+ "+bline)
+	if btags != "" {
+		result = append(result, "? "+btags+"\n")
+	}
+	return result
+}
+
+// Replace whitespace with the original whitespace characters in s, so the
+// hints line up with tabs
+func keepOriginalWhitespace(s, tags string) string {
+	var b strings.Builder
+	chars, tagChars := []rune(s), []rune(tags)
+	for i := 0; i < len(chars) && i < len(tagChars); i++ {
+		if tagChars[i] == ' ' && unicode.IsSpace(chars[i]) {
+			b.WriteRune(chars[i])
+		} else {
+			b.WriteRune(tagChars[i])
+		}
+	}
+	return b.String()
+}
+
+var lineJunkPattern = regexp.MustCompile(`^\s*(?:#\s*)?$`)
+
+// Return true for ignorable line: iff line is blank or contains a single
+// '#'.
+func IsLineJunk(line string) bool {
+	return lineJunkPattern.MatchString(line)
+}
+
+// Return true for ignorable character: iff ch is a space or tab.
+func IsCharacterJunk(ch string) bool {
+	return ch == " " || ch == "\t"
+}
+
+// Compare a and b (lists of strings); return a Differ-style delta.
+//
+// Lines are never junk, characters are junk according to IsCharacterJunk,
+// like the defaults of Python's ndiff().
+func NDiff(a, b []string) []string {
+	d := Differ{CharJunk: IsCharacterJunk}
+	return d.Compare(a, b)
+}
+
+// Like NDiff but returns the delta as a string.
+func GetNDiffString(a, b []string) string {
+	return strings.Join(NDiff(a, b), "")
+}
+
+// Given a delta produced by Differ.Compare() or NDiff(), extract lines
+// originating from file 1 or 2 (parameter which), stripping off line
+// prefixes.
+func Restore(delta []string, which int) ([]string, error) {
+	var tag string
+	switch which {
+	case 1:
+		tag = "- "
+	case 2:
+		tag = "+ "
+	default:
+		return nil, errors.New("which must be 1 or 2")
+	}
+
+	var result []string
+	for _, line := range delta {
+		if strings.HasPrefix(line, "  ") || strings.HasPrefix(line, tag) {
+			result = append(result, line[2:])
+		}
+	}
+	return result, nil
+}
+
+// Use SequenceMatcher to return list of the best "good enough" matches.
+//
+// word is a sequence for which close matches are desired (typically a
+// string).
+//
+// possibilities is a list of sequences against which to match word
+// (typically a list of strings).
+//
+// Optional arg n (default 3) is the maximum number of close matches to
+// return. n must be > 0.
+//
+// Optional arg cutoff (default 0.6) is a float in [0, 1]. Possibilities
+// that don't score at least that similar to word are ignored.
+//
+// The best (no more than n) matches among the possibilities are returned
+// in a list, sorted by similarity score, most similar first.
+func GetCloseMatches(word string, possibilities []string, n int, cutoff float64) ([]string, error) {
+	if n <= 0 {
+		return nil, errors.New("n must be > 0")
+	}
+	if cutoff < 0 || cutoff > 1 {
+		return nil, errors.New("cutoff must be in [0.0, 1.0]")
+	}
+
+	type scored struct {
+		score float64
+		x     string
+	}
+	var matches []scored
+	s := NewMatcher(nil, nil)
+	s.SetSeq2(SplitChars(word))
+	for _, x := range possibilities {
+		s.SetSeq1(SplitChars(x))
+		if s.RealQuickRatio() >= cutoff &&
+			s.QuickRatio() >= cutoff &&
+			s.Ratio() >= cutoff {
+			matches = append(matches, scored{s.Ratio(), x})
+		}
+	}
+
+	// Move the best scorers to head of list, ties are ordered like
+	// Python's heapq.nlargest() does on (score, x) pairs
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].x > matches[j].x
+	})
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+
+	result := make([]string, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, m.x)
+	}
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package difflib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDifferCompare(t *testing.T) {
+	text1 := []string{
+		"  1. Beautiful is better than ugly.\n",
+		"  2. Explicit is better than implicit.\n",
+		"  3. Simple is better than complex.\n",
+		"  4. Complex is better than complicated.\n",
+	}
+	text2 := []string{
+		"  1. Beautiful is better than ugly.\n",
+		"  3.   Simple is better than complex.\n",
+		"  4. Complicated is better than complex.\n",
+		"  5. Flat is better than nested.\n",
+	}
+	d := Differ{}
+	result := strings.Join(d.Compare(text1, text2), "")
+	expected := `    1. Beautiful is better than ugly.
+-   2. Explicit is better than implicit.
+-   3. Simple is better than complex.
++   3.   Simple is better than complex.
+?     ++
+-   4. Complex is better than complicated.
+?            ^                     ---- ^
++   4. Complicated is better than complex.
+?           ++++ ^                      ^
++   5. Flat is better than nested.
+`
+	assertEqual(t, result, expected)
+}
+
+func TestDifferAddedTabHint(t *testing.T) {
+	d := Differ{}
+	result := d.Compare([]string{"\tI am a buggy"}, []string{"\t\tI am a bug"})
+	assertEqual(t, result, []string{
+		"- \tI am a buggy",
+		"? \t          --\n",
+		"+ \t\tI am a bug",
+		"? +\n",
+	})
+}
+
+func TestDifferHintIndentedProperlyWithTabs(t *testing.T) {
+	d := Differ{}
+	result := d.Compare([]string{"\t \t \t^"}, []string{"\t \t \t^\n"})
+	assertEqual(t, result, []string{
+		"- \t \t \t^",
+		"+ \t \t \t^\n",
+		"? \t \t \t +\n",
+	})
+}
+
+func TestDifferPlainReplace(t *testing.T) {
+	// Lines without any similarity are not paired up, the shorter block
+	// comes first
+	result := NDiff([]string{"abc\n", "def\n"}, []string{"xyz\n"})
+	assertEqual(t, result, []string{"+ xyz\n", "- abc\n", "- def\n"})
+}
+
+func TestRestore(t *testing.T) {
+	delta := NDiff([]string{"one\n", "two\n", "three\n"}, []string{"ore\n", "tree\n", "emu\n"})
+
+	a, err := Restore(delta, 1)
+	assertEqual(t, err, nil)
+	assertEqual(t, a, []string{"one\n", "two\n", "three\n"})
+
+	b, err := Restore(delta, 2)
+	assertEqual(t, err, nil)
+	assertEqual(t, b, []string{"ore\n", "tree\n", "emu\n"})
+
+	_, err = Restore(delta, 3)
+	assertEqual(t, err != nil, true)
+}
+
+func TestIsJunk(t *testing.T) {
+	assertEqual(t, IsLineJunk("\n"), true)
+	assertEqual(t, IsLineJunk("  #   \n"), true)
+	assertEqual(t, IsLineJunk("hello\n"), false)
+	assertEqual(t, IsCharacterJunk(" "), true)
+	assertEqual(t, IsCharacterJunk("\t"), true)
+	assertEqual(t, IsCharacterJunk("\n"), false)
+	assertEqual(t, IsCharacterJunk("x"), false)
+}
+
+var pythonKeywords = []string{"False", "None", "True", "and", "as", "assert", "async", "await",
+	"break", "class", "continue", "def", "del", "elif", "else", "except", "finally", "for",
+	"from", "global", "if", "import", "in", "is", "lambda", "nonlocal", "not", "or", "pass",
+	"raise", "return", "try", "while", "with", "yield"}
+
+func TestGetCloseMatches(t *testing.T) {
+	matches, err := GetCloseMatches("appel", []string{"ape", "apple", "peach", "puppy"}, 3, 0.6)
+	assertEqual(t, err, nil)
+	assertEqual(t, matches, []string{"apple", "ape"})
+
+	matches, _ = GetCloseMatches("wheel", pythonKeywords, 3, 0.6)
+	assertEqual(t, matches, []string{"while"})
+
+	matches, _ = GetCloseMatches("pineapple", pythonKeywords, 3, 0.6)
+	assertEqual(t, len(matches), 0)
+
+	matches, _ = GetCloseMatches("accept", pythonKeywords, 3, 0.6)
+	assertEqual(t, matches, []string{"except"})
+
+	// Ties are ordered like Python does
+	matches, _ = GetCloseMatches("ab", []string{"ax", "ay", "ab"}, 2, 0.5)
+	assertEqual(t, matches, []string{"ab", "ay"})
+}
+
+func TestGetCloseMatchesInvalid(t *testing.T) {
+	_, err := GetCloseMatches("a", nil, 0, 0.6)
+	assertEqual(t, err != nil, true)
+	_, err = GetCloseMatches("a", nil, 3, 1.5)
+	assertEqual(t, err != nil, true)
+}
+
+func ExampleNDiff() {
+	diff := NDiff([]string{"one\n", "two\n", "three\n"}, []string{"ore\n", "tree\n", "emu\n"})
+	fmt.Print(strings.Join(diff, ""))
+	// Output:
+	// - one
+	// ?  ^
+	// + ore
+	// ?  ^
+	// - two
+	// - three
+	// ?  -
+	// + tree
+	// + emu
+}
@@ -10,6 +10,14 @@
 //
 // - context_diff
 //
+// - Differ, ndiff and restore
+//
+// - get_close_matches
+//
+// On top of that, InlineDiff, WordDiff and CharDiff compare two lines word by
+// word or character by character, and WriteHTMLDiff renders a diff as an HTML
+// table in the spirit of Python's HtmlDiff.
+//
 // Getting unified diffs was the main goal of the port. Keep in mind this code
 // is mostly suitable to output text differences in a human friendly way, there
 // are no guarantees generated diffs are consumable by patch(1).
@@ -374,34 +382,7 @@ func (m *SequenceMatcher) GetOpCodes() []OpCode {
 	if m.opCodes != nil {
 		return m.opCodes
 	}
-	i, j := 0, 0
-	matching := m.GetMatchingBlocks()
-	opCodes := make([]OpCode, 0, len(matching))
-	for _, m := range matching {
-		//  invariant:  we've pumped out correct diffs to change
-		//  a[:i] into b[:j], and the next matching block is
-		//  a[ai:ai+size] == b[bj:bj+size]. So we need to pump
-		//  out a diff to change a[i:ai] into b[j:bj], pump out
-		//  the matching block, and move (i,j) beyond the match
-		ai, bj, size := m.A, m.B, m.Size
-		tag := byte(0)
-		if i < ai && j < bj {
-			tag = 'r'
-		} else if i < ai {
-			tag = 'd'
-		} else if j < bj {
-			tag = 'i'
-		}
-		if tag > 0 {
-			opCodes = append(opCodes, OpCode{tag, i, ai, j, bj})
-		}
-		i, j = ai+size, bj+size
-		// the list of matching blocks is terminated by a
-		// sentinel with size 0
-		if size > 0 {
-			opCodes = append(opCodes, OpCode{'e', ai, i, bj, j})
-		}
-	}
+	opCodes := matchingBlocksToOpCodes(m.GetMatchingBlocks())
 	m.opCodes = opCodes
 	return m.opCodes
 }
@@ -411,44 +392,7 @@ func (m *SequenceMatcher) GetOpCodes() []OpCode {
 // Return a generator of groups with up to n lines of context.
 // Each group is in the same format as returned by GetOpCodes().
 func (m *SequenceMatcher) GetGroupedOpCodes(n int) [][]OpCode {
-	if n < 0 {
-		n = 3
-	}
-	codes := m.GetOpCodes()
-	if len(codes) == 0 {
-		codes = []OpCode{OpCode{'e', 0, 1, 0, 1}}
-	}
-	// Fixup leading and trailing groups if they show no changes.
-	if codes[0].Tag == 'e' {
-		c := codes[0]
-		i1, i2, j1, j2 := c.I1, c.I2, c.J1, c.J2
-		codes[0] = OpCode{c.Tag, max(i1, i2-n), i2, max(j1, j2-n), j2}
-	}
-	if codes[len(codes)-1].Tag == 'e' {
-		c := codes[len(codes)-1]
-		i1, i2, j1, j2 := c.I1, c.I2, c.J1, c.J2
-		codes[len(codes)-1] = OpCode{c.Tag, i1, min(i2, i1+n), j1, min(j2, j1+n)}
-	}
-	nn := n + n
-	groups := [][]OpCode{}
-	group := []OpCode{}
-	for _, c := range codes {
-		i1, i2, j1, j2 := c.I1, c.I2, c.J1, c.J2
-		// End the current group and start a new one whenever
-		// there is a large range with no changes.
-		if c.Tag == 'e' && i2-i1 > nn {
-			group = append(group, OpCode{c.Tag, i1, min(i2, i1+n),
-				j1, min(j2, j1+n)})
-			groups = append(groups, group)
-			group = []OpCode{}
-			i1, j1 = max(i1, i2-n), max(j1, j2-n)
-		}
-		group = append(group, OpCode{c.Tag, i1, i2, j1, j2})
-	}
-	if len(group) > 0 && !(len(group) == 1 && group[0].Tag == 'e') {
-		groups = append(groups, group)
-	}
-	return groups
+	return GroupOpCodes(m.GetOpCodes(), n)
 }
 
 // Return a measure of the sequences' similarity (float in [0,1]).
@@ -535,6 +479,8 @@ type UnifiedDiff struct {
 	ToDate   string   // Second file time
 	Eol      string   // Headers end of line, defaults to LF
 	Context  int      // Number of context lines
+	// Algorithm compares the lines, nil means SequenceMatcher
+	Algorithm Algorithm
 }
 
 // Compare two sequences of lines; generate the delta as a unified diff.
@@ -573,8 +519,7 @@ func WriteUnifiedDiff(writer io.Writer, diff UnifiedDiff) error {
 	}
 
 	started := false
-	m := NewMatcher(diff.A, diff.B)
-	for _, g := range m.GetGroupedOpCodes(diff.Context) {
+	for _, g := range groupedOpCodes(diff.A, diff.B, diff.Algorithm, diff.Context) {
 		if !started {
 			started = true
 			fromDate := ""
@@ -700,8 +645,7 @@ func WriteContextDiff(writer io.Writer, diff ContextDiff) error {
 	}
 
 	started := false
-	m := NewMatcher(diff.A, diff.B)
-	for _, g := range m.GetGroupedOpCodes(diff.Context) {
+	for _, g := range groupedOpCodes(diff.A, diff.B, diff.Algorithm, diff.Context) {
 		if !started {
 			started = true
 			fromDate := ""
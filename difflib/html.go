@@ -0,0 +1,254 @@
+package difflib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Inline CSS of the HTML diff, mail clients ignore style sheets
+const (
+	htmlTableStyle     = "border-collapse:collapse;font-family:Menlo,Consolas,monospace;font-size:12px"
+	htmlHeaderStyle    = "padding:4px 8px;background-color:#f6f8fa;border-bottom:1px solid #d0d7de;text-align:left"
+	htmlNumberStyle    = "padding:0 8px;color:#8c959f;text-align:right;vertical-align:top;user-select:none"
+	htmlLineStyle      = "padding:0 8px;white-space:pre-wrap;word-break:break-all;vertical-align:top"
+	htmlDeletedStyle   = "background-color:#ffebe9"
+	htmlInsertedStyle  = "background-color:#e6ffec"
+	htmlDelStyle       = "background-color:#ffc1c0;text-decoration:none"
+	htmlInsStyle       = "background-color:#abf2bc;text-decoration:none"
+	htmlCollapsedStyle = "padding:2px 8px;color:#57606a;background-color:#ddf4ff;text-align:center"
+)
+
+// HTML diff parameters
+type HTMLDiff struct {
+	A          []string // First sequence lines
+	FromFile   string   // First file name
+	B          []string // Second sequence lines
+	ToFile     string   // Second file name
+	Context    int      // Number of context lines, other unchanged lines are collapsed
+	SideBySide bool     // Render two columns instead of one
+	// Split tokenizes replaced lines to highlight what changed within them,
+	// defaults to SplitWords
+	Split func(string) []string
+	// Algorithm compares the lines, nil means SequenceMatcher
+	Algorithm Algorithm
+}
+
+type htmlWriter struct {
+	buf  *bufio.Writer
+	diff HTMLDiff
+	err  error
+}
+
+func (w *htmlWriter) ws(s string) {
+	_, err := w.buf.WriteString(s)
+	if w.err == nil && err != nil {
+		w.err = err
+	}
+}
+
+func (w *htmlWriter) wf(format string, args ...interface{}) {
+	w.ws(fmt.Sprintf(format, args...))
+}
+
+func trimEol(line string) string {
+	return strings.TrimRight(line, "\r\n")
+}
+
+func lineNumber(i int) string {
+	if i < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", i+1)
+}
+
+func (w *htmlWriter) number(i int) {
+	w.wf(`<td style="%s">%s</td>`, htmlNumberStyle, lineNumber(i))
+}
+
+func (w *htmlWriter) line(style string, content string) {
+	if style != "" {
+		style = ";" + style
+	}
+	w.wf(`<td style="%s%s">%s</td>`, htmlLineStyle, style, content)
+}
+
+// highlight renders the side of spans tagged with tag, with the changed text
+// wrapped in element
+func highlight(spans []Span, tag byte, element string, style string) string {
+	var b strings.Builder
+	for _, s := range spans {
+		if s.Tag == 'e' {
+			b.WriteString(html.EscapeString(s.Text))
+		} else if s.Tag == tag {
+			fmt.Fprintf(&b, `<%s style="%s">%s</%s>`, element, style, html.EscapeString(s.Text), element)
+		}
+	}
+	return b.String()
+}
+
+func (w *htmlWriter) collapsed(count int) {
+	columns := 3
+	if w.diff.SideBySide {
+		columns = 4
+	}
+	w.wf(`<tr><td colspan="%d" style="%s">&#8943; %d unchanged lines &#8943;</td></tr>`+"\n",
+		columns, htmlCollapsedStyle, count)
+}
+
+// row writes one line of the diff. i and j are the line numbers in A and
+// B, -1 if the line is missing on that side.
+func (w *htmlWriter) row(i, j int, oldText, newText, style string) {
+	w.ws("<tr>")
+	if w.diff.SideBySide {
+		oldStyle, newStyle := "", ""
+		if i >= 0 && style != "" {
+			oldStyle = htmlDeletedStyle
+		}
+		if j >= 0 && style != "" {
+			newStyle = htmlInsertedStyle
+		}
+		w.number(i)
+		w.line(oldStyle, oldText)
+		w.number(j)
+		w.line(newStyle, newText)
+	} else {
+		w.number(i)
+		w.number(j)
+		if j >= 0 {
+			w.line(style, newText)
+		} else {
+			w.line(style, oldText)
+		}
+	}
+	w.ws("</tr>\n")
+}
+
+func (w *htmlWriter) opCode(c OpCode) {
+	a, b := w.diff.A, w.diff.B
+	switch c.Tag {
+	case 'e':
+		// An algorithm may match lines which are not identical, like ones
+		// differing in case, so each side shows its own text
+		for k := 0; k < c.I2-c.I1; k++ {
+			w.row(c.I1+k, c.J1+k, html.EscapeString(trimEol(a[c.I1+k])), html.EscapeString(trimEol(b[c.J1+k])), "")
+		}
+		return
+	case 'd':
+		for i := c.I1; i < c.I2; i++ {
+			w.row(i, -1, html.EscapeString(trimEol(a[i])), "", htmlDeletedStyle)
+		}
+		return
+	case 'i':
+		for j := c.J1; j < c.J2; j++ {
+			w.row(-1, j, "", html.EscapeString(trimEol(b[j])), htmlInsertedStyle)
+		}
+		return
+	}
+
+	// Replaced lines are paired up, so the changes within them can be
+	// highlighted
+	split := w.diff.Split
+	if split == nil {
+		split = SplitWords
+	}
+	count := max(c.I2-c.I1, c.J2-c.J1)
+	oldTexts, newTexts := make([]string, count), make([]string, count)
+	for k := 0; k < count; k++ {
+		i, j := c.I1+k, c.J1+k
+		switch {
+		case i < c.I2 && j < c.J2:
+			spans := InlineDiff(trimEol(a[i]), trimEol(b[j]), split)
+			oldTexts[k] = highlight(spans, 'd', "del", htmlDelStyle)
+			newTexts[k] = highlight(spans, 'i', "ins", htmlInsStyle)
+		case i < c.I2:
+			oldTexts[k] = html.EscapeString(trimEol(a[i]))
+		default:
+			newTexts[k] = html.EscapeString(trimEol(b[j]))
+		}
+	}
+
+	if w.diff.SideBySide {
+		for k := 0; k < count; k++ {
+			i, j := c.I1+k, c.J1+k
+			if i >= c.I2 {
+				i = -1
+			}
+			if j >= c.J2 {
+				j = -1
+			}
+			w.row(i, j, oldTexts[k], newTexts[k], htmlDeletedStyle)
+		}
+		return
+	}
+
+	for i := c.I1; i < c.I2; i++ {
+		w.row(i, -1, oldTexts[i-c.I1], "", htmlDeletedStyle)
+	}
+	for j := c.J1; j < c.J2; j++ {
+		w.row(-1, j, "", newTexts[j-c.J1], htmlInsertedStyle)
+	}
+}
+
+// Compare two sequences of lines; generate the delta as an HTML table.
+//
+// Similar to Python's HtmlDiff.make_table(), the table shows the changed
+// lines with line numbers and diff.Context unchanged lines around them,
+// longer unchanged regions are collapsed into a single row. Within replaced
+// lines the changed words are marked with <del> and <ins>. The inline view
+// lists removed lines above the added ones, the side-by-side view shows the
+// first sequence on the left and the second one on the right.
+//
+// All styles are inline, so the table renders in mail clients. Nothing is
+// written if the sequences are equal.
+func WriteHTMLDiff(writer io.Writer, diff HTMLDiff) error {
+	buf := bufio.NewWriter(writer)
+	w := &htmlWriter{buf: buf, diff: diff}
+
+	groups := groupedOpCodes(diff.A, diff.B, diff.Algorithm, diff.Context)
+	if len(groups) == 0 {
+		return nil
+	}
+
+	w.wf(`<table style="%s">`+"\n", htmlTableStyle)
+	if diff.FromFile != "" || diff.ToFile != "" {
+		if diff.SideBySide {
+			w.wf(`<tr><th colspan="2" style="%s">%s</th><th colspan="2" style="%s">%s</th></tr>`+"\n",
+				htmlHeaderStyle, html.EscapeString(diff.FromFile), htmlHeaderStyle, html.EscapeString(diff.ToFile))
+		} else {
+			w.wf(`<tr><th colspan="3" style="%s">%s &#8594; %s</th></tr>`+"\n",
+				htmlHeaderStyle, html.EscapeString(diff.FromFile), html.EscapeString(diff.ToFile))
+		}
+	}
+
+	next := 0
+	for _, g := range groups {
+		first, last := g[0], g[len(g)-1]
+		if first.I1 > next {
+			w.collapsed(first.I1 - next)
+		}
+		for _, c := range g {
+			w.opCode(c)
+		}
+		next = last.I2
+	}
+	if next < len(diff.A) {
+		w.collapsed(len(diff.A) - next)
+	}
+	w.ws("</table>\n")
+	if w.err != nil {
+		return w.err
+	}
+
+	return buf.Flush()
+}
+
+// Like WriteHTMLDiff but returns the diff a string.
+func GetHTMLDiffString(diff HTMLDiff) (string, error) {
+	w := &bytes.Buffer{}
+	err := WriteHTMLDiff(w, diff)
+	return string(w.Bytes()), err
+}
@@ -0,0 +1,114 @@
+package difflib
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var htmlDiffA = SplitLines("1\n2\n3\n4\n5\n6\n7\n8\n<b>Price:</b> 10 €\n9\n10\n11\n12")
+var htmlDiffB = SplitLines("1\n2\n3\n4\n5\n6\n7\n8\n<b>Price:</b> 12 €\nnew\n9\n10\n11\n12")
+
+func htmlRows(s string) []string {
+	var rows []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(line, "<tr>") {
+			rows = append(rows, line)
+		}
+	}
+	return rows
+}
+
+func TestHTMLDiffInline(t *testing.T) {
+	result, err := GetHTMLDiffString(HTMLDiff{A: htmlDiffA, B: htmlDiffB, FromFile: "Old", ToFile: "Current", Context: 1})
+	assertEqual(t, err, nil)
+
+	rows := htmlRows(result)
+	assertEqual(t, len(rows), 8)
+	assertEqual(t, strings.Contains(rows[0], "Old &#8594; Current"), true)
+	assertEqual(t, strings.Contains(rows[1], "7 unchanged lines"), true)
+	assertEqual(t, strings.Count(rows[2], ">8</td>"), 3)
+	assertEqual(t, strings.Contains(rows[3], ">9</td><td style=\""+htmlNumberStyle+"\"></td>"), true)
+	assertEqual(t, strings.Contains(rows[3], "&lt;b&gt;Price:&lt;/b&gt; <del style=\""+htmlDelStyle+"\">10</del> €"), true)
+	assertEqual(t, strings.Contains(rows[4], "&lt;b&gt;Price:&lt;/b&gt; <ins style=\""+htmlInsStyle+"\">12</ins> €"), true)
+	assertEqual(t, strings.Contains(rows[5], htmlInsertedStyle+"\">new</td>"), true)
+	assertEqual(t, strings.Contains(rows[7], "3 unchanged lines"), true)
+
+	// Only inline styles are used
+	assertEqual(t, strings.Contains(result, "class="), false)
+	assertEqual(t, strings.Contains(result, "<style"), false)
+}
+
+func TestHTMLDiffSideBySide(t *testing.T) {
+	result, err := GetHTMLDiffString(HTMLDiff{A: htmlDiffA, B: htmlDiffB, FromFile: "Old", ToFile: "Current", Context: 0, SideBySide: true})
+	assertEqual(t, err, nil)
+
+	rows := htmlRows(result)
+	assertEqual(t, len(rows), 5)
+	assertEqual(t, strings.Contains(rows[0], ">Old</th>"), true)
+	assertEqual(t, strings.Contains(rows[0], ">Current</th>"), true)
+	assertEqual(t, strings.Contains(rows[1], "colspan=\"4\""), true)
+	assertEqual(t, strings.Contains(rows[1], "8 unchanged lines"), true)
+	// The replaced line is shown on both sides of one row
+	assertEqual(t, strings.Contains(rows[2], "<del style=\""+htmlDelStyle+"\">10</del>"), true)
+	assertEqual(t, strings.Contains(rows[2], "<ins style=\""+htmlInsStyle+"\">12</ins>"), true)
+	// The added line has no counterpart on the left
+	assertEqual(t, strings.Contains(rows[3], "<td style=\""+htmlNumberStyle+"\"></td><td style=\""+htmlLineStyle+"\"></td>"), true)
+	assertEqual(t, strings.Contains(rows[3], ">new</td>"), true)
+	assertEqual(t, strings.Contains(rows[4], "4 unchanged lines"), true)
+}
+
+func TestHTMLDiffCharSplit(t *testing.T) {
+	result, err := GetHTMLDiffString(HTMLDiff{A: []string{"colour\n"}, B: []string{"color\n"}, Split: SplitChars})
+	assertEqual(t, err, nil)
+	assertEqual(t, strings.Contains(result, "colo<del style=\""+htmlDelStyle+"\">u</del>r"), true)
+}
+
+func TestHTMLDiffEqual(t *testing.T) {
+	result, err := GetHTMLDiffString(HTMLDiff{A: htmlDiffA, B: htmlDiffA, FromFile: "Old", ToFile: "Current"})
+	assertEqual(t, err, nil)
+	assertEqual(t, result, "")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestHTMLDiffWriteError(t *testing.T) {
+	err := WriteHTMLDiff(failingWriter{}, HTMLDiff{A: htmlDiffA, B: htmlDiffB})
+	assertEqual(t, err, errors.New("disk full"))
+}
+
+// caseless matches up lines regardless of their case
+type caseless struct{}
+
+func (caseless) OpCodes(a, b []string) []OpCode {
+	lower := func(lines []string) []string {
+		result := make([]string, len(lines))
+		for i, line := range lines {
+			result[i] = strings.ToLower(line)
+		}
+		return result
+	}
+	return NewMatcher(lower(a), lower(b)).GetOpCodes()
+}
+
+func TestHTMLDiffEqualLinesShowBothSides(t *testing.T) {
+	a, b := []string{"Price\n", "10\n"}, []string{"PRICE\n", "12\n"}
+	result, err := GetHTMLDiffString(HTMLDiff{A: a, B: b, Context: 1, SideBySide: true, Algorithm: caseless{}})
+	assertEqual(t, err, nil)
+
+	rows := htmlRows(result)
+	assertEqual(t, len(rows), 2)
+	assertEqual(t, strings.Contains(rows[0], ">Price</td>"), true)
+	assertEqual(t, strings.Contains(rows[0], ">PRICE</td>"), true)
+
+	// The inline view shows the current text
+	result, err = GetHTMLDiffString(HTMLDiff{A: a, B: b, Context: 1, Algorithm: caseless{}})
+	assertEqual(t, err, nil)
+	rows = htmlRows(result)
+	assertEqual(t, strings.Contains(rows[0], ">PRICE</td>"), true)
+	assertEqual(t, strings.Contains(rows[0], ">Price</td>"), false)
+}
@@ -0,0 +1,107 @@
+package difflib
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Span is a piece of an intra-line diff. Tag is 'e' for text both strings
+// share, 'd' for text only found in the first string and 'i' for text only
+// found in the second one.
+type Span struct {
+	Tag  byte
+	Text string
+}
+
+const (
+	classWord = iota
+	classSpace
+	classPunct
+)
+
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+		return classWord
+	case unicode.IsSpace(r):
+		return classSpace
+	}
+	return classPunct
+}
+
+// Split a string into words, runs of white space and single punctuation
+// characters. Joining the tokens gives back s.
+func SplitWords(s string) []string {
+	var tokens []string
+	for start := 0; start < len(s); {
+		r, size := utf8.DecodeRuneInString(s[start:])
+		end := start + size
+		class := runeClass(r)
+		for class != classPunct && end < len(s) {
+			next, nextSize := utf8.DecodeRuneInString(s[end:])
+			if runeClass(next) != class {
+				break
+			}
+			end += nextSize
+		}
+		tokens = append(tokens, s[start:end])
+		start = end
+	}
+	return tokens
+}
+
+// Split a string into its characters. Joining the tokens gives back s.
+func SplitChars(s string) []string {
+	tokens := make([]string, 0, len(s))
+	for _, r := range s {
+		tokens = append(tokens, string(r))
+	}
+	return tokens
+}
+
+// Compare two strings token by token, using split to tokenize them, and
+// return the spans turning a into b.
+//
+// Adjacent spans never share a tag, and a replaced piece of text is
+// reported as a 'd' span directly followed by an 'i' span. Joining the 'e'
+// and 'd' spans gives back a, joining the 'e' and 'i' spans gives back b.
+//
+// The automatic junk heuristic is disabled, as short tokens like spaces are
+// expected to be frequent.
+func InlineDiff(a, b string, split func(string) []string) []Span {
+	tokensA, tokensB := split(a), split(b)
+	m := NewMatcherWithJunk(tokensA, tokensB, false, nil)
+
+	var spans []Span
+	add := func(tag byte, tokens []string) {
+		if len(tokens) == 0 {
+			return
+		}
+		text := strings.Join(tokens, "")
+		if n := len(spans); n > 0 && spans[n-1].Tag == tag {
+			spans[n-1].Text += text
+			return
+		}
+		spans = append(spans, Span{Tag: tag, Text: text})
+	}
+	for _, c := range m.GetOpCodes() {
+		if c.Tag == 'e' {
+			add('e', tokensA[c.I1:c.I2])
+			continue
+		}
+		add('d', tokensA[c.I1:c.I2])
+		add('i', tokensB[c.J1:c.J2])
+	}
+	return spans
+}
+
+// Like InlineDiff with word granularity.
+func WordDiff(a, b string) []Span {
+	return InlineDiff(a, b, SplitWords)
+}
+
+// Like InlineDiff with character granularity.
+func CharDiff(a, b string) []Span {
+	return InlineDiff(a, b, SplitChars)
+}
@@ -0,0 +1,79 @@
+package difflib
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func joinSpans(spans []Span, skip byte) string {
+	var b strings.Builder
+	for _, s := range spans {
+		if s.Tag != skip {
+			b.WriteString(s.Text)
+		}
+	}
+	return b.String()
+}
+
+func TestSplitWords(t *testing.T) {
+	assertEqual(t, SplitWords("Price: 1.299,00 €  now"),
+		[]string{"Price", ":", " ", "1", ".", "299", ",", "00", " ", "€", "  ", "now"})
+	assertEqual(t, SplitWords("grüße_2"), []string{"grüße_2"})
+	assertEqual(t, len(SplitWords("")), 0)
+}
+
+func TestSplitChars(t *testing.T) {
+	assertEqual(t, SplitChars("a€b"), []string{"a", "€", "b"})
+}
+
+func TestWordDiff(t *testing.T) {
+	a := "<h1>This is a heading</h1>"
+	b := "<h1>This is a new heading</h1>"
+	spans := WordDiff(a, b)
+	assertEqual(t, spans, []Span{
+		{'e', "<h1>This is a "},
+		{'i', "new "},
+		{'e', "heading</h1>"},
+	})
+	assertEqual(t, joinSpans(spans, 'i'), a)
+	assertEqual(t, joinSpans(spans, 'd'), b)
+}
+
+func TestWordDiffReplace(t *testing.T) {
+	spans := WordDiff("Price: 10 €", "Price: 12 €")
+	assertEqual(t, spans, []Span{
+		{'e', "Price: "},
+		{'d', "10"},
+		{'i', "12"},
+		{'e', " €"},
+	})
+}
+
+func TestCharDiff(t *testing.T) {
+	spans := CharDiff("colour", "color")
+	assertEqual(t, spans, []Span{{'e', "colo"}, {'d', "u"}, {'e', "r"}})
+
+	assertEqual(t, len(CharDiff("", "")), 0)
+	assertEqual(t, CharDiff("", "ab"), []Span{{'i', "ab"}})
+}
+
+func TestInlineDiffFrequentTokens(t *testing.T) {
+	// Without autojunk, frequent tokens like spaces still match
+	a := strings.Repeat("a ", 150) + "b"
+	b := strings.Repeat("a ", 150) + "c"
+	spans := WordDiff(a, b)
+	assertEqual(t, spans, []Span{{'e', strings.Repeat("a ", 150)}, {'d', "b"}, {'i', "c"}})
+}
+
+func ExampleWordDiff() {
+	for _, span := range WordDiff("Only 10 € today", "Only 12 € today and tomorrow") {
+		fmt.Printf("%c %q\n", span.Tag, span.Text)
+	}
+	// Output:
+	// e "Only "
+	// d "10"
+	// i "12"
+	// e " € today"
+	// i " and tomorrow"
+}
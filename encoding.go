@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding lists the content codings decodeBody understands
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// decodeBody undoes the codings of a Content-Encoding header. Several codings
+// are applied in the listed order, so they are undone from the last one.
+func decodeBody(contentEncoding string, body []byte) ([]byte, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		var err error
+		body, err = decodeCoding(coding, body)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to decode %s Response: %s", coding, err)
+		}
+	}
+
+	return body, nil
+}
+
+func decodeCoding(coding string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	switch coding {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case "deflate":
+		// deflate should be wrapped in zlib, but some servers send the raw
+		// stream
+		zlibReader, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			zlibReader = flate.NewReader(bytes.NewReader(body))
+		}
+		defer zlibReader.Close()
+		reader = zlibReader
+	case "br":
+		reader = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		decoder, err := zstd.NewReader(bytes.NewReader(body), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		reader = decoder
+	default:
+		return nil, fmt.Errorf("Unsupported Content-Encoding")
+	}
+
+	return ioutil.ReadAll(reader)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compress encodes body with the content coding
+func compress(t *testing.T, coding string, body []byte) []byte {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	var err error
+	switch coding {
+	case "gzip":
+		writer = gzip.NewWriter(&buffer)
+	case "deflate":
+		writer = zlib.NewWriter(&buffer)
+	case "rawDeflate":
+		writer, err = flate.NewWriter(&buffer, flate.DefaultCompression)
+	case "br":
+		writer = brotli.NewWriter(&buffer)
+	case "zstd":
+		writer, err = zstd.NewWriter(&buffer)
+	}
+	require.NoError(t, err, "Expected no error")
+
+	_, err = writer.Write(body)
+	require.NoError(t, err, "Expected no error")
+	require.NoError(t, writer.Close())
+
+	return buffer.Bytes()
+}
+
+func TestGetContentDecoding(t *testing.T) {
+	tests := []struct {
+		contentEncoding string
+		body            []byte
+	}{
+		{"gzip", compress(t, "gzip", htmlBody)},
+		{"deflate", compress(t, "deflate", htmlBody)},
+		{"deflate", compress(t, "rawDeflate", htmlBody)},
+		{"br", compress(t, "br", htmlBody)},
+		{"zstd", compress(t, "zstd", htmlBody)},
+		{"gzip, br", compress(t, "br", compress(t, "gzip", htmlBody))},
+		{"identity", htmlBody},
+	}
+
+	for _, test := range tests {
+		testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, acceptEncoding, req.Header.Get("Accept-Encoding"))
+			res.Header().Set("Content-Encoding", test.contentEncoding)
+			res.Write(test.body)
+		}))
+
+		response, _, err := getContent(testServer.URL, fetchOptions{}, nil)
+		testServer.Close()
+		require.NoError(t, err, test.contentEncoding)
+		assert.Equal(t, htmlBody, response, test.contentEncoding)
+	}
+}
+
+func TestDecodeBodyError(t *testing.T) {
+	_, err := decodeBody("compress", htmlBody)
+	assert.EqualError(t, err, "Unable to decode compress Response: Unsupported Content-Encoding")
+
+	_, err = decodeBody("gzip", htmlBody)
+	assert.EqualError(t, err, "Unable to decode gzip Response: gzip: invalid header")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// extractOptions select the part of a page which is compared
+type extractOptions struct {
+	// Selector is a CSS selector, only the matching elements are compared
+	Selector string `yaml:"selector"`
+	// Exclude lists CSS selectors of elements which are never compared,
+	// like ads, footers or forms with CSRF tokens
+	Exclude []string `yaml:"exclude"`
+	// XPath is an XPath 1.0 expression, used instead of Selector
+	XPath string `yaml:"xpath"`
+	// XPathResult is nodes (default) or text
+	XPathResult string `yaml:"xpathResult"`
+	// Format is html (default), xml or json and decides how the body is
+	// parsed
+	Format string `yaml:"format"`
+	// JSONPath selects the compared part of a JSON document
+	JSONPath string `yaml:"jsonPath"`
+	// NotifyOnNoMatch sends a notification when Selector or XPath stop
+	// matching anything
+	NotifyOnNoMatch bool `yaml:"notifyOnNoMatch"`
+	// Text compares the readable text of the extracted HTML instead of its
+	// markup
+	Text bool `yaml:"text"`
+}
+
+func (options extractOptions) validate() error {
+	for _, selector := range append([]string{options.Selector}, options.Exclude...) {
+		if selector == "" {
+			continue
+		}
+
+		_, err := cascadia.Compile(selector)
+		if err != nil {
+			return fmt.Errorf("Invalid CSS selector %q: %s", selector, err)
+		}
+	}
+
+	err := options.validateXPath()
+	if err != nil {
+		return err
+	}
+
+	err = options.validateJSON()
+	if err != nil {
+		return err
+	}
+
+	if options.Text && (options.Format == formatXML || options.Format == formatJSON || options.XPathResult == xpathResultText) {
+		return fmt.Errorf("Text mode needs HTML content")
+	}
+
+	return nil
+}
+
+// noMatchError is returned when an expression selecting the compared content
+// does not match anything, which usually means the page layout changed
+type noMatchError struct {
+	kind       string
+	expression string
+}
+
+func (e noMatchError) Error() string {
+	return fmt.Sprintf("%s %q no longer matches anything", e.kind, e.expression)
+}
+
+// processContent turns the raw response body into the content which is
+// compared between two crawls
+func processContent(w watch, body string) (string, error) {
+	if w.Extract.Format == formatJSON {
+		return extractJSON(body, w.Extract)
+	}
+
+	content, err := extractMarkup(body, w.Extract)
+	if err != nil || !w.Extract.Text {
+		return content, err
+	}
+
+	return htmlToText(content)
+}
+
+// extractMarkup applies the CSS selectors or the XPath expression to an HTML
+// or XML body
+func extractMarkup(body string, options extractOptions) (string, error) {
+	if options.XPath == "" {
+		return extractSelection(body, options)
+	}
+
+	if len(options.Exclude) > 0 && options.Format != formatXML {
+		var err error
+		body, err = extractSelection(body, extractOptions{Exclude: options.Exclude})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return extractXPath(body, options)
+}
+
+func extractSelection(body string, options extractOptions) (string, error) {
+	if options.Selector == "" && len(options.Exclude) == 0 {
+		return body, nil
+	}
+
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse HTML: %s", err)
+	}
+
+	for _, exclude := range options.Exclude {
+		document.Find(exclude).Remove()
+	}
+
+	if options.Selector == "" {
+		return document.Html()
+	}
+
+	selection := document.Find(options.Selector)
+	if selection.Length() == 0 {
+		return "", noMatchError{kind: "CSS selector", expression: options.Selector}
+	}
+
+	var fragments []string
+	for i := range selection.Nodes {
+		fragment, err := goquery.OuterHtml(selection.Eq(i))
+		if err != nil {
+			return "", err
+		}
+		fragments = append(fragments, fragment)
+	}
+
+	return strings.Join(fragments, "\n"), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var htmlShop = `<!DOCTYPE html>
+<html>
+<head><title>Shop</title></head>
+<body>
+<div class="ad">Buy now!</div>
+<div id="product">
+  <h1>Widget</h1>
+  <span class="price">10 €</span>
+  <form><input type="hidden" name="csrf" value="abc123"></form>
+</div>
+<div id="product-2"><h1>Gadget</h1></div>
+<footer>Rendered at 10:00:01</footer>
+</body>
+</html>`
+
+func TestExtractSelectionDisabled(t *testing.T) {
+	content, err := extractSelection(htmlShop, extractOptions{})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, htmlShop, content)
+}
+
+func TestExtractSelectionSelector(t *testing.T) {
+	content, err := extractSelection(htmlShop, extractOptions{Selector: "#product h1, .price"})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "<h1>Widget</h1>\n<span class=\"price\">10 €</span>", content)
+}
+
+func TestExtractSelectionExclude(t *testing.T) {
+	content, err := extractSelection(htmlShop, extractOptions{Selector: "#product", Exclude: []string{"form"}})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "<div id=\"product\">\n  <h1>Widget</h1>\n  <span class=\"price\">10 €</span>\n  \n</div>", content)
+
+	content, err = extractSelection(htmlShop, extractOptions{Exclude: []string{".ad", "footer", "form", "head"}})
+	require.NoError(t, err, "Expected no error")
+	assert.NotContains(t, content, "Buy now!")
+	assert.NotContains(t, content, "Rendered at")
+	assert.NotContains(t, content, "csrf")
+	assert.Contains(t, content, "<h1>Gadget</h1>")
+}
+
+func TestExtractSelectionNoMatch(t *testing.T) {
+	_, err := extractSelection(htmlShop, extractOptions{Selector: "#missing"})
+	assert.Equal(t, "CSS selector \"#missing\" no longer matches anything", err.Error())
+	assert.IsType(t, noMatchError{}, err)
+}
+
+func TestExtractOptionsValidate(t *testing.T) {
+	assert.NoError(t, extractOptions{}.validate())
+	assert.NoError(t, extractOptions{Selector: "#product > h1", Exclude: []string{"footer"}}.validate())
+
+	err := extractOptions{Exclude: []string{"div["}}.validate()
+	assert.Contains(t, err.Error(), "Invalid CSS selector \"div[\"")
+}
+
+func TestProcessContent(t *testing.T) {
+	w := defaultWatch()
+	w.Extract.Selector = ".price"
+
+	content, err := processContent(w, htmlShop)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "<span class=\"price\">10 €</span>", content)
+}
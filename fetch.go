@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// defaultHeaders are sent unless a watch overrides them
+var defaultHeaders = map[string]string{
+	"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8",
+	"Accept-Language": "de-DE,de;q=0.9,en-US;q=0.8,en;q=0.7,es;q=0.6",
+	"Cache-Control":   "no-cache",
+	"Pragma":          "no-cache",
+	"User-Agent":      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_2) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/71.0.3578.98 Safari/537.36",
+}
+
+// variablePattern finds ${NAME} references to environment variables
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// fetchOptions describe the request for the watched page. ${NAME} in the
+// URL, header values and the body is replaced by the environment variable
+// NAME when the request is sent, so secrets stay out of the configuration.
+type fetchOptions struct {
+	Timeout time.Duration `yaml:"timeout"`
+	// Method defaults to GET, or POST if a body is set
+	Method string `yaml:"method"`
+	// Headers replace the default headers of the same name
+	Headers map[string]string `yaml:"headers"`
+	// UserAgent replaces the default User-Agent
+	UserAgent string `yaml:"userAgent"`
+	// Body is sent as it is, Form URL encoded and JSON encoded as JSON, only
+	// one of them can be set
+	Body string            `yaml:"body"`
+	Form map[string]string `yaml:"form"`
+	JSON interface{}       `yaml:"json"`
+	Auth authOptions       `yaml:"auth"`
+	TLS  clientTLSOptions  `yaml:"tls"`
+	// Cookies keeps the cookies set by the site in the database and sends
+	// them with the following requests
+	Cookies bool `yaml:"cookies"`
+	// Steps replace the single request by a sequence of requests, like
+	// loading a login form, posting it and fetching the watched page
+	Steps []stepOptions `yaml:"steps"`
+}
+
+func (options fetchOptions) validate() error {
+	if options.Timeout < 0 {
+		return fmt.Errorf("Timeout must not be negative")
+	}
+
+	notToken := func(r rune) bool { return !httpguts.IsTokenRune(r) }
+	if strings.IndexFunc(options.Method, notToken) >= 0 {
+		return fmt.Errorf("Invalid HTTP method: %s", options.Method)
+	}
+
+	for name, value := range options.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("Invalid header %q", name)
+		}
+	}
+
+	bodies := 0
+	for _, set := range []bool{options.Body != "", options.Form != nil, options.JSON != nil} {
+		if set {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		return fmt.Errorf("Please specify only one of body, form and json")
+	}
+
+	err := options.Auth.validate()
+	if err != nil {
+		return err
+	}
+
+	for i, step := range options.Steps {
+		err = step.validate(options)
+		if err != nil {
+			return fmt.Errorf("Step %d: %s", i+1, err)
+		}
+	}
+
+	return options.TLS.validate()
+}
+
+// newClient returns the client fetching the watched page, jar may be nil
+func (options fetchOptions) newClient(jar http.CookieJar) (*http.Client, error) {
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = time.Second * 10
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Jar:     jar,
+	}
+
+	if options.TLS.enabled() {
+		config, err := options.TLS.config()
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		client.Transport = transport
+	}
+
+	if strings.ToLower(options.Auth.Type) == authDigest {
+		next := client.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		client.Transport = &digestTransport{options: options.Auth, next: next}
+	}
+
+	return client, nil
+}
+
+// interpolate replaces ${NAME} by the variable NAME extracted by a step, or
+// by the environment variable NAME
+func interpolate(text string, vars variables) (string, error) {
+	var err error
+	result := variablePattern.ReplaceAllStringFunc(text, func(reference string) string {
+		name := variablePattern.FindStringSubmatch(reference)[1]
+		if value, found := vars[name]; found {
+			return value
+		}
+		value, found := os.LookupEnv(name)
+		if !found && err == nil {
+			err = fmt.Errorf("Environment variable %s is not set", name)
+		}
+		return value
+	})
+
+	return result, err
+}
+
+// interpolateJSON interpolates every string of a decoded JSON or YAML value
+func interpolateJSON(value interface{}, vars variables) (interface{}, error) {
+	var err error
+	switch v := value.(type) {
+	case string:
+		return interpolate(v, vars)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key], err = interpolateJSON(item, vars)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i], err = interpolateJSON(item, vars)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	return value, nil
+}
+
+// requestBody returns the interpolated body and its content type
+func (options fetchOptions) requestBody(vars variables) (io.Reader, string, error) {
+	switch {
+	case options.Form != nil:
+		form := url.Values{}
+		for name, value := range options.Form {
+			value, err := interpolate(value, vars)
+			if err != nil {
+				return nil, "", err
+			}
+			form.Set(name, value)
+		}
+		return strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil
+	case options.JSON != nil:
+		value, err := interpolateJSON(options.JSON, vars)
+		if err != nil {
+			return nil, "", err
+		}
+		body, err := json.Marshal(value)
+		if err != nil {
+			return nil, "", err
+		}
+		return bytes.NewReader(body), "application/json", nil
+	case options.Body != "":
+		body, err := interpolate(options.Body, vars)
+		if err != nil {
+			return nil, "", err
+		}
+		return strings.NewReader(body), "", nil
+	}
+
+	return nil, "", nil
+}
+
+// newRequest builds the request for scanUrl
+func newRequest(scanUrl string, options fetchOptions, vars variables) (*http.Request, error) {
+	scanUrl, err := interpolate(scanUrl, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	body, contentType, err := options.requestBody(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	method := strings.ToUpper(options.Method)
+	if method == "" {
+		method = http.MethodGet
+		if body != nil {
+			method = http.MethodPost
+		}
+	}
+
+	request, err := http.NewRequest(method, scanUrl, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range defaultHeaders {
+		request.Header.Set(name, value)
+	}
+	// Setting Accept-Encoding turns off the transparent gzip decoding of
+	// net/http, decodeBody handles the response instead
+	request.Header.Set("Accept-Encoding", acceptEncoding)
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if options.UserAgent != "" {
+		request.Header.Set("User-Agent", options.UserAgent)
+	}
+
+	err = options.Auth.authorize(request)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range options.Headers {
+		value, err = interpolate(value, vars)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set(name, value)
+	}
+
+	return request, nil
+}
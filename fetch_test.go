@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setenv sets an environment variable for the duration of the test
+func setenv(t *testing.T, name string, value string) {
+	previous, found := os.LookupEnv(name)
+	require.NoError(t, os.Setenv(name, value))
+	t.Cleanup(func() {
+		if found {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func TestNewRequestDefaults(t *testing.T) {
+	request, err := newRequest("https://www.test.com", fetchOptions{}, nil)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, http.MethodGet, request.Method)
+	assert.Nil(t, request.Body)
+	assert.Equal(t, defaultHeaders["User-Agent"], request.Header.Get("User-Agent"))
+	assert.Equal(t, defaultHeaders["Accept-Language"], request.Header.Get("Accept-Language"))
+	assert.Equal(t, acceptEncoding, request.Header.Get("Accept-Encoding"))
+}
+
+func TestNewRequestOverrides(t *testing.T) {
+	setenv(t, "WD_TEST_TOKEN", "secret")
+	setenv(t, "WD_TEST_QUERY", "shoes")
+
+	options := fetchOptions{
+		Method:    "put",
+		UserAgent: "WD/1.0",
+		Headers:   map[string]string{"Authorization": "Bearer ${WD_TEST_TOKEN}", "Accept-Language": "en"},
+		Body:      "q=${WD_TEST_QUERY}&price=$5",
+	}
+	request, err := newRequest("https://www.test.com/search?key=${WD_TEST_TOKEN}", options, nil)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, http.MethodPut, request.Method)
+	assert.Equal(t, "https://www.test.com/search?key=secret", request.URL.String())
+	assert.Equal(t, "WD/1.0", request.Header.Get("User-Agent"))
+	assert.Equal(t, "Bearer secret", request.Header.Get("Authorization"))
+	assert.Equal(t, "en", request.Header.Get("Accept-Language"))
+	assert.Equal(t, "", request.Header.Get("Content-Type"))
+	body, err := ioutil.ReadAll(request.Body)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "q=shoes&price=$5", string(body))
+
+	request, err = newRequest("https://www.test.com", fetchOptions{Form: map[string]string{"q": "${WD_TEST_QUERY}", "page": "1"}}, nil)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, http.MethodPost, request.Method)
+	assert.Equal(t, "application/x-www-form-urlencoded", request.Header.Get("Content-Type"))
+	body, err = ioutil.ReadAll(request.Body)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "page=1&q=shoes", string(body))
+
+	json := map[string]interface{}{"query": "${WD_TEST_QUERY}", "filters": []interface{}{"${WD_TEST_TOKEN}\"", 2}}
+	request, err = newRequest("https://www.test.com", fetchOptions{JSON: json, Headers: map[string]string{"Content-Type": "application/vnd.api+json"}}, nil)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "application/vnd.api+json", request.Header.Get("Content-Type"))
+	body, err = ioutil.ReadAll(request.Body)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, `{"filters":["secret\"",2],"query":"shoes"}`, string(body))
+	assert.Equal(t, "${WD_TEST_QUERY}", json["query"])
+}
+
+func TestNewRequestMissingVariable(t *testing.T) {
+	_, err := newRequest("https://www.test.com", fetchOptions{Headers: map[string]string{"Authorization": "Bearer ${WD_TEST_UNSET}"}}, nil)
+	assert.EqualError(t, err, "Environment variable WD_TEST_UNSET is not set")
+}
+
+func TestFetchValidate(t *testing.T) {
+	assert.NoError(t, fetchOptions{Method: "PATCH", Headers: map[string]string{"X-Token": "${TOKEN}"}, Form: map[string]string{"q": "a"}}.validate())
+	assert.EqualError(t, fetchOptions{Method: "GET /"}.validate(), "Invalid HTTP method: GET /")
+	assert.EqualError(t, fetchOptions{Headers: map[string]string{"X Token": "a"}}.validate(), "Invalid header \"X Token\"")
+	assert.EqualError(t, fetchOptions{Body: "a", Form: map[string]string{"q": "a"}}.validate(), "Please specify only one of body, form and json")
+	assert.EqualError(t, fetchOptions{Form: map[string]string{"q": "a"}, JSON: "a"}.validate(), "Please specify only one of body, form and json")
+}
+
+func TestGetContentPost(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "shoes", req.FormValue("q"))
+		res.Write([]byte("<p>3 results</p>"))
+	}))
+	defer func() { testServer.Close() }()
+
+	response, _, err := getContent(testServer.URL, fetchOptions{Form: map[string]string{"q": "shoes"}}, nil)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "<p>3 results</p>", string(response))
+}
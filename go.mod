@@ -3,11 +3,21 @@ module WD
 go 1.15
 
 require (
+	github.com/PuerkitoBio/goquery v1.6.1
+	github.com/andybalholm/brotli v1.0.5
+	github.com/andybalholm/cascadia v1.1.0
+	github.com/antchfx/htmlquery v1.2.3
+	github.com/antchfx/xmlquery v1.3.5
+	github.com/antchfx/xpath v1.1.10
+	github.com/klauspost/compress v1.13.6
 	github.com/labstack/gommon v0.3.0
 	github.com/mattn/go-sqlite3 v1.14.6
-	github.com/p0l0/web-content-change-detector v0.0.0-20210319165401-84d75c1ea91f
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/net v0.0.0-20200813134508-3edf25e44fcc
+	golang.org/x/text v0.3.0
 	gopkg.in/DATA-DOG/go-sqlmock.v1 v1.3.0
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df
+	gopkg.in/yaml.v3 v3.0.1
 )
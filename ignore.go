@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const defaultPlaceholder = "[ignored]"
+
+// ignoreOptions mask volatile parts of the compared content, like
+// timestamps, session IDs or view counters
+type ignoreOptions struct {
+	// Patterns are regular expressions whose matches are replaced by
+	// Placeholder
+	Patterns []string `yaml:"patterns"`
+	// Lines are regular expressions, every line matching one of them is
+	// dropped
+	Lines       []string `yaml:"lines"`
+	Placeholder string   `yaml:"placeholder"`
+}
+
+func compileIgnoreRules(kind string, expressions []string) ([]*regexp.Regexp, error) {
+	var result []*regexp.Regexp
+	for _, expression := range expressions {
+		re, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ignore %s %q: %s", kind, expression, err)
+		}
+		result = append(result, re)
+	}
+
+	return result, nil
+}
+
+func (options ignoreOptions) validate() error {
+	_, _, err := options.compile()
+
+	return err
+}
+
+func (options ignoreOptions) compile() ([]*regexp.Regexp, []*regexp.Regexp, error) {
+	patterns, err := compileIgnoreRules("pattern", options.Patterns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lines, err := compileIgnoreRules("line", options.Lines)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return patterns, lines, nil
+}
+
+// maskContent drops the ignored lines of content and replaces the matches of
+// the ignore patterns. It also returns how many regions were masked, every
+// dropped line and every replaced match counting as one.
+func maskContent(content string, options ignoreOptions) (string, int, error) {
+	patterns, lines, err := options.compile()
+	if err != nil {
+		return "", 0, err
+	}
+
+	masked := 0
+	if len(lines) > 0 {
+		var kept strings.Builder
+		for _, line := range strings.SplitAfter(content, "\n") {
+			if matchesAny(lines, strings.TrimRight(line, "\r\n")) {
+				masked++
+				continue
+			}
+			kept.WriteString(line)
+		}
+		content = kept.String()
+	}
+
+	placeholder := options.Placeholder
+	if placeholder == "" {
+		placeholder = defaultPlaceholder
+	}
+
+	for _, pattern := range patterns {
+		matches := len(pattern.FindAllStringIndex(content, -1))
+		if matches == 0 {
+			continue
+		}
+		masked += matches
+		content = pattern.ReplaceAllLiteralString(content, placeholder)
+	}
+
+	return content, masked, nil
+}
+
+// maskJSON masks the string and number values of a JSON document instead of
+// its lines, so it stays valid. Values matching a line rule are dropped, and
+// numbers with a masked match become strings.
+func maskJSON(content string, options ignoreOptions) (string, int, error) {
+	patterns, lines, err := options.compile()
+	if err != nil || content == "" || len(patterns) == 0 && len(lines) == 0 {
+		return content, 0, err
+	}
+
+	document, err := parseJSON(content)
+	if err != nil {
+		return "", 0, err
+	}
+
+	placeholder := options.Placeholder
+	if placeholder == "" {
+		placeholder = defaultPlaceholder
+	}
+
+	masked := 0
+	var mask func(value interface{}) (interface{}, bool)
+	mask = func(value interface{}) (interface{}, bool) {
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			for key, item := range typed {
+				item, keep := mask(item)
+				if keep {
+					typed[key] = item
+				} else {
+					delete(typed, key)
+				}
+			}
+		case []interface{}:
+			kept := typed[:0]
+			for _, item := range typed {
+				item, keep := mask(item)
+				if keep {
+					kept = append(kept, item)
+				}
+			}
+			return kept, true
+		case string, json.Number:
+			text := fmt.Sprint(typed)
+			if matchesAny(lines, text) {
+				masked++
+				return nil, false
+			}
+			for _, pattern := range patterns {
+				matches := len(pattern.FindAllStringIndex(text, -1))
+				if matches == 0 {
+					continue
+				}
+				masked += matches
+				text = pattern.ReplaceAllLiteralString(text, placeholder)
+				value = text
+			}
+		}
+		return value, true
+	}
+
+	document, _ = mask(document)
+
+	return canonicalJSON(document, "  ") + "\n", masked, nil
+}
+
+func matchesAny(expressions []*regexp.Regexp, text string) bool {
+	for _, re := range expressions {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+
+	return false
+}
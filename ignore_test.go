@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var volatilePage = `<p>Widget 10 €</p>
+<p>Rendered at 10:00:01</p>
+<a href="/cart?sid=4f2a9c">Cart</a> <a href="/help?sid=4f2a9c">Help</a>
+<p>1234 views</p>
+`
+
+func TestMaskContent(t *testing.T) {
+	options := ignoreOptions{Patterns: []string{`sid=[0-9a-f]+`, `\d+ views`}, Lines: []string{`^<p>Rendered at`}}
+
+	content, masked, err := maskContent(volatilePage, options)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, `<p>Widget 10 €</p>
+<a href="/cart?[ignored]">Cart</a> <a href="/help?[ignored]">Help</a>
+<p>[ignored]</p>
+`, content)
+	assert.Equal(t, 4, masked)
+
+	options.Placeholder = "***"
+	content, _, err = maskContent(volatilePage, options)
+	require.NoError(t, err, "Expected no error")
+	assert.Contains(t, content, "<p>***</p>")
+}
+
+func TestMaskContentDisabled(t *testing.T) {
+	content, masked, err := maskContent(volatilePage, ignoreOptions{})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, volatilePage, content)
+	assert.Equal(t, 0, masked)
+}
+
+func TestMaskContentHidesVolatileChanges(t *testing.T) {
+	options := ignoreOptions{Patterns: []string{`\d\d:\d\d:\d\d`}}
+	oldContent, _, err := maskContent("<p>Widget</p>\n<p>Rendered at 10:00:01</p>\n", options)
+	require.NoError(t, err, "Expected no error")
+	newContent, _, err := maskContent("<p>Widget</p>\n<p>Rendered at 11:30:12</p>\n", options)
+	require.NoError(t, err, "Expected no error")
+
+	diffs, err := getDifferences(snapshot{Content: oldContent}, snapshot{Content: newContent}, diffOptions{Context: 3})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "", diffs.Text)
+}
+
+func TestMaskJSON(t *testing.T) {
+	options := ignoreOptions{Patterns: []string{`sid=[0-9a-f]+`, `^\d{10}$`}, Lines: []string{`^Rendered at`}}
+	document := `{"footer": "Rendered at 10:00:01", "links": ["/cart?sid=4f2a9c", "Rendered at 10:00:01"], "time": 1700000000, "price": 10}`
+
+	content, masked, err := maskJSON(document, options)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, `{
+  "links": [
+    "/cart?[ignored]"
+  ],
+  "price": 10,
+  "time": "[ignored]"
+}
+`, content)
+	assert.Equal(t, 4, masked)
+
+	content, masked, err = maskJSON("", options)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "", content)
+	assert.Equal(t, 0, masked)
+}
+
+func TestCheckWatchMasksJSON(t *testing.T) {
+	pages := []string{
+		`{"rendered": "10:00:01", "price": 10}`,
+		`{"rendered": "10:05:12", "price": 10}`,
+		`{"rendered": "10:10:44", "price": 12}`,
+	}
+	var requests int
+	site := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte(pages[requests]))
+		requests++
+	}))
+	defer site.Close()
+
+	var received []webhookPayload
+	hook := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err, "Expected no error")
+		var payload webhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		received = append(received, payload)
+	}))
+	defer hook.Close()
+
+	db := openTestDB(t)
+	w := defaultWatch()
+	w.URL = site.URL
+	w.Extract.Format = formatJSON
+	w.Ignore.Patterns = []string{`\d\d:\d\d:\d\d`}
+	w.Notify = []notifierOptions{{Type: notifierWebhook, URL: hook.URL, Retries: intPointer(0)}}
+
+	for range pages {
+		require.NoError(t, checkWatch(context.Background(), db, w))
+	}
+
+	require.Len(t, received, 1)
+	assert.Equal(t, "changed $.price: 10 -> 12\n", received[0].Diff)
+}
+
+func TestIgnoreOptionsValidate(t *testing.T) {
+	assert.NoError(t, ignoreOptions{Patterns: []string{`\d+`}, Lines: []string{`^#`}}.validate())
+
+	err := ignoreOptions{Patterns: []string{`(`}}.validate()
+	assert.Contains(t, err.Error(), "Invalid ignore pattern \"(\"")
+
+	err = ignoreOptions{Lines: []string{`[`}}.validate()
+	assert.Contains(t, err.Error(), "Invalid ignore line \"[\"")
+}
+
+func TestEmailDifferencesMasked(t *testing.T) {
+	c := change{Text: sendEmailDiff.text, HTML: sendEmailDiff.html}
+	assert.Equal(t, sendEmailDiff, emailDifferences(c))
+
+	c.Masked = 3
+	diffs := emailDifferences(c)
+	assert.Equal(t, sendEmailDiff.text+"\n3 regions were masked by ignore rules.\n", diffs.text)
+	assert.Equal(t, sendEmailDiff.html+"<p>3 regions were masked by ignore rules.</p>", diffs.html)
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const formatJSON = "json"
+
+// jsonPathStep is one selector of a JSONPath, like .name, [2] or [*]. A
+// recursive step (..name) applies its selector to a node and all its
+// descendants.
+type jsonPathStep struct {
+	recursive bool
+	wildcard  bool
+	name      string
+	index     *int
+}
+
+// parseJSONPath parses the JSONPath subset used to select part of a JSON
+// document: $, .name, ['name'], [n] with negative indexes counting from the
+// end, [*], .* and recursive descent with ..
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath must start with $")
+	}
+
+	var steps []jsonPathStep
+	rest := path[1:]
+	for rest != "" {
+		var step jsonPathStep
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				break
+			}
+			fallthrough
+		case strings.HasPrefix(rest, "."):
+			rest = strings.TrimPrefix(rest, ".")
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("Missing name at %q", rest)
+			}
+			if rest[:end] == "*" {
+				step.wildcard = true
+			} else {
+				step.name = rest[:end]
+			}
+			rest = rest[end:]
+			steps = append(steps, step)
+			continue
+		case !strings.HasPrefix(rest, "["):
+			return nil, fmt.Errorf("Unexpected %q", rest)
+		}
+
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("Missing ] in %q", rest)
+		}
+		selector := strings.TrimSpace(rest[1:end])
+		rest = rest[end+1:]
+
+		switch {
+		case selector == "*":
+			step.wildcard = true
+		case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+			step.name = selector[1 : len(selector)-1]
+		default:
+			index, err := strconv.Atoi(selector)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid selector [%s]", selector)
+			}
+			step.index = &index
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// definite reports whether the path selects at most one value
+func definite(steps []jsonPathStep) bool {
+	for _, step := range steps {
+		if step.recursive || step.wildcard {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (step jsonPathStep) apply(value interface{}) []interface{} {
+	var result []interface{}
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if step.wildcard {
+			for _, key := range sortedKeys(typed) {
+				result = append(result, typed[key])
+			}
+		} else if child, ok := typed[step.name]; ok && step.index == nil {
+			result = append(result, child)
+		}
+	case []interface{}:
+		if step.wildcard {
+			result = append(result, typed...)
+		} else if step.index != nil {
+			index := *step.index
+			if index < 0 {
+				index += len(typed)
+			}
+			if index >= 0 && index < len(typed) {
+				result = append(result, typed[index])
+			}
+		}
+	}
+
+	return result
+}
+
+// descendants returns value and every value nested in it, in document order
+func descendants(value interface{}) []interface{} {
+	result := []interface{}{value}
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(typed) {
+			result = append(result, descendants(typed[key])...)
+		}
+	case []interface{}:
+		for _, child := range typed {
+			result = append(result, descendants(child)...)
+		}
+	}
+
+	return result
+}
+
+func selectJSON(document interface{}, steps []jsonPathStep) []interface{} {
+	current := []interface{}{document}
+	for _, step := range steps {
+		var next []interface{}
+		for _, value := range current {
+			candidates := []interface{}{value}
+			if step.recursive {
+				candidates = descendants(value)
+			}
+			for _, candidate := range candidates {
+				next = append(next, step.apply(candidate)...)
+			}
+		}
+		current = next
+	}
+
+	return current
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func parseJSON(body string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	// Numbers are kept as written, so large integers do not lose precision
+	decoder.UseNumber()
+
+	var document interface{}
+	err := decoder.Decode(&document)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse JSON: %s", err)
+	}
+
+	return document, nil
+}
+
+// canonicalJSON encodes value with sorted keys, indented with prefix, or on
+// one line if indent is empty
+func canonicalJSON(value interface{}, indent string) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	// Values come from parseJSON and always encode
+	encoder.Encode(value)
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// extractJSON parses body, selects the part matching options.JSONPath and
+// returns it canonicalized, so reordered keys or changed formatting are not
+// reported as changes
+func extractJSON(body string, options extractOptions) (string, error) {
+	document, err := parseJSON(body)
+	if err != nil {
+		return "", err
+	}
+
+	if options.JSONPath != "" {
+		steps, err := parseJSONPath(options.JSONPath)
+		if err != nil {
+			return "", fmt.Errorf("Invalid JSONPath %q: %s", options.JSONPath, err)
+		}
+
+		matches := selectJSON(document, steps)
+		if len(matches) == 0 {
+			return "", noMatchError{kind: "JSONPath", expression: options.JSONPath}
+		}
+
+		if definite(steps) {
+			document = matches[0]
+		} else {
+			document = matches
+		}
+	}
+
+	return canonicalJSON(document, "  ") + "\n", nil
+}
+
+func (options extractOptions) validateJSON() error {
+	if options.Format != formatJSON {
+		if options.JSONPath != "" {
+			return fmt.Errorf("Please set format to json to use a JSONPath")
+		}
+		return nil
+	}
+
+	if options.Selector != "" || options.XPath != "" || len(options.Exclude) > 0 {
+		return fmt.Errorf("CSS selectors and XPath expressions can not be used with JSON")
+	}
+
+	if options.JSONPath != "" {
+		_, err := parseJSONPath(options.JSONPath)
+		if err != nil {
+			return fmt.Errorf("Invalid JSONPath %q: %s", options.JSONPath, err)
+		}
+	}
+
+	return nil
+}
+
+const (
+	jsonAdded   = "added"
+	jsonRemoved = "removed"
+	jsonChanged = "changed"
+)
+
+// jsonChange is one difference between two JSON documents. Old and New hold
+// the compact JSON of the values, depending on Kind one of them is empty.
+type jsonChange struct {
+	Kind string
+	Path string
+	Old  string
+	New  string
+}
+
+var jsonIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func jsonChildPath(path string, key string) string {
+	if jsonIdentifier.MatchString(key) {
+		return path + "." + key
+	}
+
+	return path + "['" + strings.Replace(strings.Replace(key, `\`, `\\`, -1), "'", `\'`, -1) + "']"
+}
+
+// compareJSON lists the paths which differ between oldValue and newValue.
+// Objects are compared key by key and arrays index by index, ignoreCase
+// compares the values in lower case.
+func compareJSON(path string, oldValue interface{}, newValue interface{}, ignoreCase bool) []jsonChange {
+	switch oldTyped := oldValue.(type) {
+	case map[string]interface{}:
+		newTyped, ok := newValue.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := sortedKeys(oldTyped)
+		for key := range newTyped {
+			if _, ok := oldTyped[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		var changes []jsonChange
+		for _, key := range keys {
+			oldChild, inOld := oldTyped[key]
+			newChild, inNew := newTyped[key]
+			childPath := jsonChildPath(path, key)
+			switch {
+			case !inOld:
+				changes = append(changes, jsonChange{Kind: jsonAdded, Path: childPath, New: canonicalJSON(newChild, "")})
+			case !inNew:
+				changes = append(changes, jsonChange{Kind: jsonRemoved, Path: childPath, Old: canonicalJSON(oldChild, "")})
+			default:
+				changes = append(changes, compareJSON(childPath, oldChild, newChild, ignoreCase)...)
+			}
+		}
+
+		return changes
+	case []interface{}:
+		newTyped, ok := newValue.([]interface{})
+		if !ok {
+			break
+		}
+
+		var changes []jsonChange
+		for i := 0; i < len(oldTyped) || i < len(newTyped); i++ {
+			childPath := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= len(oldTyped):
+				changes = append(changes, jsonChange{Kind: jsonAdded, Path: childPath, New: canonicalJSON(newTyped[i], "")})
+			case i >= len(newTyped):
+				changes = append(changes, jsonChange{Kind: jsonRemoved, Path: childPath, Old: canonicalJSON(oldTyped[i], "")})
+			default:
+				changes = append(changes, compareJSON(childPath, oldTyped[i], newTyped[i], ignoreCase)...)
+			}
+		}
+
+		return changes
+	}
+
+	oldJSON, newJSON := canonicalJSON(oldValue, ""), canonicalJSON(newValue, "")
+	if oldJSON == newJSON || ignoreCase && strings.ToLower(oldJSON) == strings.ToLower(newJSON) {
+		return nil
+	}
+
+	return []jsonChange{{Kind: jsonChanged, Path: path, Old: oldJSON, New: newJSON}}
+}
+
+func (c jsonChange) String() string {
+	switch c.Kind {
+	case jsonAdded:
+		return fmt.Sprintf("added %s: %s", c.Path, c.New)
+	case jsonRemoved:
+		return fmt.Sprintf("removed %s: %s", c.Path, c.Old)
+	}
+
+	return fmt.Sprintf("changed %s: %s -> %s", c.Path, c.Old, c.New)
+}
+
+// getJSONDifferences reports the structural differences between two
+// documents produced by extractJSON. An empty oldContent, from a crawl which
+// did not match, counts as if the whole document was added.
+func getJSONDifferences(oldContent string, newContent string, ignoreCase bool) (differences, changeStats, error) {
+	var result differences
+	var stats changeStats
+
+	newValue, err := parseJSON(newContent)
+	if err != nil {
+		return result, stats, err
+	}
+
+	var changes []jsonChange
+	if oldContent == "" {
+		changes = []jsonChange{{Kind: jsonAdded, Path: "$", New: canonicalJSON(newValue, "")}}
+	} else {
+		oldValue, err := parseJSON(oldContent)
+		if err != nil {
+			return result, stats, err
+		}
+		changes = compareJSON("$", oldValue, newValue, ignoreCase)
+	}
+
+	if len(changes) == 0 {
+		return result, stats, nil
+	}
+
+	var text, markup strings.Builder
+	markup.WriteString("<table>")
+	for _, c := range changes {
+		// A changed value counts like a modified line in a unified diff
+		switch c.Kind {
+		case jsonAdded:
+			stats.Added++
+		case jsonRemoved:
+			stats.Removed++
+		default:
+			stats.Added++
+			stats.Removed++
+			stats.Changed++
+		}
+
+		text.WriteString(c.String() + "\n")
+		fmt.Fprintf(&markup, "<tr><td>%s</td><td><code>%s</code></td><td><del>%s</del></td><td><ins>%s</ins></td></tr>",
+			c.Kind, html.EscapeString(c.Path), html.EscapeString(c.Old), html.EscapeString(c.New))
+	}
+	markup.WriteString("</table>")
+
+	result.text = text.String()
+	result.html = markup.String()
+
+	return result, stats, nil
+}
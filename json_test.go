@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var jsonProducts = `{"updated":"2021-03-01T10:00:00Z","products":[{"name":"Widget","price":10,"tags":["new"]},{"name":"Gadget","price":12.50}],"total":12345678901234567890}`
+
+func TestExtractJSONCanonical(t *testing.T) {
+	content, err := extractJSON(`{"b": 1,  "a": {"d": "<x>", "c": null}}`, extractOptions{Format: formatJSON})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "{\n  \"a\": {\n    \"c\": null,\n    \"d\": \"<x>\"\n  },\n  \"b\": 1\n}\n", content)
+
+	// Large numbers keep their precision
+	content, err = extractJSON(jsonProducts, extractOptions{Format: formatJSON, JSONPath: "$.total"})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "12345678901234567890\n", content)
+
+	_, err = extractJSON(`{"a":`, extractOptions{Format: formatJSON})
+	assert.Contains(t, err.Error(), "Unable to parse JSON")
+}
+
+func TestExtractJSONPath(t *testing.T) {
+	tests := map[string]string{
+		"$":                      canonicalJSON(mustParseJSON(t, jsonProducts), "  "),
+		"$.products[0].name":     `"Widget"`,
+		"$['products'][-1].name": `"Gadget"`,
+		"$.products[*].price":    "[\n  10,\n  12.50\n]",
+		"$.products.*.name":      "[\n  \"Widget\",\n  \"Gadget\"\n]",
+		"$..tags[0]":             "[\n  \"new\"\n]",
+	}
+
+	for path, expected := range tests {
+		content, err := extractJSON(jsonProducts, extractOptions{Format: formatJSON, JSONPath: path})
+		require.NoError(t, err, path)
+		assert.Equal(t, expected+"\n", content, path)
+	}
+}
+
+func TestExtractJSONPathNoMatch(t *testing.T) {
+	_, err := extractJSON(jsonProducts, extractOptions{Format: formatJSON, JSONPath: "$.products[5]"})
+	assert.Equal(t, "JSONPath \"$.products[5]\" no longer matches anything", err.Error())
+	assert.IsType(t, noMatchError{}, err)
+}
+
+func TestParseJSONPathInvalid(t *testing.T) {
+	for _, path := range []string{"products", "$.", "$[abc]", "$[0", "$x"} {
+		_, err := parseJSONPath(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestExtractOptionsValidateJSON(t *testing.T) {
+	assert.NoError(t, extractOptions{Format: formatJSON, JSONPath: "$.products[*]"}.validate())
+
+	err := extractOptions{JSONPath: "$.a"}.validate()
+	assert.EqualError(t, err, "Please set format to json to use a JSONPath")
+
+	err = extractOptions{Format: formatJSON, Selector: "div"}.validate()
+	assert.EqualError(t, err, "CSS selectors and XPath expressions can not be used with JSON")
+
+	err = extractOptions{Format: formatJSON, JSONPath: "$["}.validate()
+	assert.Contains(t, err.Error(), "Invalid JSONPath \"$[\"")
+}
+
+func TestGetJSONDifferences(t *testing.T) {
+	w := defaultWatch()
+	w.Extract.Format = formatJSON
+	oldContent, err := processContent(w, jsonProducts)
+	require.NoError(t, err, "Expected no error")
+
+	// Reordered keys and whitespace are no change
+	sameContent, err := processContent(w, `{"total":12345678901234567890,"products":[{"price":10,"name":"Widget","tags":["new"]},{"price":12.50,"name":"Gadget"}],"updated":"2021-03-01T10:00:00Z"}`)
+	require.NoError(t, err, "Expected no error")
+	diffs, err := compareContent(w, snapshot{Content: oldContent}, snapshot{Content: sameContent})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "", diffs.Text)
+	assert.Equal(t, changeStats{}, diffs.Stats)
+
+	newContent, err := processContent(w, `{"updated":"2021-03-02T10:00:00Z","products":[{"name":"Widget","price":9,"tags":[]},{"name":"Gadget","price":12.50},{"name":"Gizmo","price":3}],"total":12345678901234567890,"next page":2}`)
+	require.NoError(t, err, "Expected no error")
+	diffs, err = compareContent(w, snapshot{Content: oldContent, CrawlTime: "2021-03-01 10:00:00"}, snapshot{Content: newContent, CrawlTime: "2021-03-02 10:00:00"})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, `added $['next page']: 2
+changed $.products[0].price: 10 -> 9
+removed $.products[0].tags[0]: "new"
+added $.products[2]: {"name":"Gizmo","price":3}
+changed $.updated: "2021-03-01T10:00:00Z" -> "2021-03-02T10:00:00Z"
+`, diffs.Text)
+	assert.Contains(t, diffs.HTML, "<tr><td>added</td><td><code>$[&#39;next page&#39;]</code></td><td><del></del></td><td><ins>2</ins></td></tr>")
+	assert.Equal(t, changeStats{Added: 4, Removed: 3, Changed: 2}, diffs.Stats)
+	assert.Equal(t, "2021-03-01 10:00:00", diffs.Old.CrawlTime)
+	assert.Equal(t, "2021-03-02 10:00:00", diffs.New.CrawlTime)
+}
+
+func TestGetJSONDifferencesTypeChange(t *testing.T) {
+	diffs, _, err := getJSONDifferences(`{"a":[1]}`, `{"a":{"b":1}}`, false)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "changed $.a: [1] -> {\"b\":1}\n", diffs.text)
+
+	// The previous crawl did not match the JSONPath
+	diffs, stats, err := getJSONDifferences("", `[1]`, false)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "added $: [1]\n", diffs.text)
+	assert.Equal(t, changeStats{Added: 1}, stats)
+}
+
+func mustParseJSON(t *testing.T, body string) interface{} {
+	value, err := parseJSON(body)
+	require.NoError(t, err, "Expected no error")
+
+	return value
+}
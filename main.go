@@ -1,19 +1,26 @@
 package main
 
 import (
-	"crypto/tls"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/p0l0/web-content-change-detector/difflib"
+	"WD/difflib"
 	"gopkg.in/gomail.v2"
 	"html"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"os/signal"
 	"strings"
-	"time"
+	"sync"
+	"syscall"
 )
 
 type dbRow struct {
@@ -27,38 +34,95 @@ type differences struct {
 	html string
 }
 
-func getContent(scanUrl string) ([]byte, error) {
-	client := &http.Client{
-		Timeout: time.Second * 10,
+// getContent fetches scanUrl, or runs the steps of options, and returns the
+// body transcoded to UTF-8 with the name of its original charset. jar may be
+// nil.
+func getContent(scanUrl string, options fetchOptions, jar http.CookieJar) ([]byte, string, error) {
+	if len(options.Steps) > 0 && jar == nil {
+		// The steps of a login share the session even if it is not kept
+		var err error
+		jar, err = cookiejar.New(nil)
+		if err != nil {
+			return nil, "", err
+		}
 	}
-	request, err := http.NewRequest("GET", scanUrl, nil)
+
+	client, err := options.newClient(jar)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	request.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8")
-	request.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	request.Header.Set("Accept-Language", "de-DE,de;q=0.9,en-US;q=0.8,en;q=0.7,es;q=0.6")
-	request.Header.Set("Cache-Control", "no-cache")
-	request.Header.Set("Pragma", "no-cache")
-	request.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_2) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/71.0.3578.98 Safari/537.36")
+	if len(options.Steps) == 0 {
+		body, _, charset, err := fetchPage(client, scanUrl, options, nil)
+		return body, charset, err
+	}
+
+	vars := variables{}
+	var body []byte
+	var charset string
+	for i, step := range options.Steps {
+		stepUrl := step.URL
+		if stepUrl == "" {
+			stepUrl = scanUrl
+		}
+
+		var header http.Header
+		body, header, charset, err = fetchPage(client, stepUrl, step.fetchOptions(options), vars)
+		if err != nil {
+			return nil, "", fmt.Errorf("Step %d: %s", i+1, err)
+		}
+
+		for name, variable := range step.Extract {
+			vars[name], err = extractVariable(variable, header, string(body))
+			if err != nil {
+				return nil, "", fmt.Errorf("Step %d: Variable %s: %s", i+1, name, err)
+			}
+		}
+	}
+
+	return body, charset, nil
+}
+
+// fetchPage sends one request and returns the decoded body with the
+// response headers and the original charset
+func fetchPage(client *http.Client, scanUrl string, options fetchOptions, vars variables) ([]byte, http.Header, string, error) {
+	request, err := newRequest(scanUrl, options, vars)
+	if err != nil {
+		return nil, nil, "", err
+	}
 
 	response, err := client.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("Error getting Response: %s", err)
+		return nil, nil, "", fmt.Errorf("Error getting Response: %s", err)
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != 200 {
-		return nil, fmt.Errorf("Incorrect HTTP Status Code: %s", response.Status)
+		return nil, nil, "", fmt.Errorf("Incorrect HTTP Status Code: %s", response.Status)
 	}
 
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to read Response: %s", err)
+		return nil, nil, "", fmt.Errorf("Unable to read Response: %s", err)
+	}
+
+	// The decoded body is stored
+	body, err = decodeBody(response.Header.Get("Content-Encoding"), body)
+	if err != nil {
+		return nil, nil, "", err
 	}
 
-	return body, nil
+	body, charset, err := decodeCharset(response.Header.Get("Content-Type"), body)
+
+	return body, response.Header, charset, err
+}
+
+// openDB opens the sqlite database in a mode that allows several watches to be
+// checked at once: WAL lets readers work next to the writer, the busy timeout
+// makes concurrent writers wait for each other instead of failing and
+// immediate transactions avoid deadlocks when a read lock is upgraded.
+func openDB(path string) (*sql.DB, error) {
+	return sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=10000&_txlock=immediate")
 }
 
 func initializeDB(db *sql.DB) error {
@@ -71,33 +135,81 @@ func initializeDB(db *sql.DB) error {
 		return err
 	}
 
-	return nil
+	// content holds the part of the response which is compared, the raw
+	// response is kept so extraction settings can change without re-fetching
+	err = addColumn(db, "responseData", "content", "text")
+	if err != nil {
+		return err
+	}
+
+	// charset is the charset the response was sent in before it was
+	// transcoded to UTF-8
+	err = addColumn(db, "responseData", "charset", "text")
+	if err != nil {
+		return err
+	}
+
+	// changeLog keeps every detected change, including those below the
+	// threshold of their watch
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS changeLog (url text, fromDate text, toDate text, lines integer, chars integer, ratio real, significant integer, diff text);")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS valueHistory (url text, crawlTime text, value real);")
+	if err != nil {
+		return err
+	}
+
+	// cookies holds the Set-Cookie values of watches which keep cookies
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS cookies (watch text, url text, domain text, path text, name text, cookie text);")
+
+	return err
+}
+
+// addColumn adds a column to tables created by older versions
+func addColumn(db *sql.DB, table string, column string, columnType string) error {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + columnType)
+
+	return err
 }
 
-func insertRecoredData(db *sql.DB, scanUrl string, response []byte) (error) {
+func insertRecoredData(db *sql.DB, scanUrl string, response []byte, charset string, content string) (error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 
-	stmt, err := tx.Prepare("INSERT INTO responseData(url, crawlTime, response) values(?, datetime('now'), ?)")
+	stmt, err := tx.Prepare("INSERT INTO responseData(url, crawlTime, response, charset, content) values(?, datetime('now'), ?, ?, ?)")
 	if err != nil {
+		// An open transaction would keep the database locked for other watches
+		tx.Rollback()
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(scanUrl, fmt.Sprintf("%s", response))
+	_, err = stmt.Exec(scanUrl, fmt.Sprintf("%s", response), charset, content)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
-	tx.Commit()
 
-	return nil
+	return tx.Commit()
 }
 
 func getLastEntries(db *sql.DB, scanUrl string) ([]dbRow, error) {
-	rows, err := db.Query("SELECT url, datetime(crawlTime) AS crawlTime, response FROM responseData WHERE url = ? ORDER BY crawlTime DESC LIMIT 2", scanUrl)
+	// Crawls within the same second are ordered by insertion
+	rows, err := db.Query("SELECT url, datetime(crawlTime) AS crawlTime, response FROM responseData WHERE url = ? ORDER BY crawlTime DESC, rowid DESC LIMIT 2", scanUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -121,109 +233,473 @@ func getLastEntries(db *sql.DB, scanUrl string) ([]dbRow, error) {
 	return resultData, nil
 }
 
-func getDifferences(newResponse string, oldResponse string) (differences, error) {
+// snapshot is the compared content of one crawl
+type snapshot struct {
+	Content   string
+	CrawlTime string
+}
+
+// diffResult is the outcome of comparing two snapshots. Old is always the
+// earlier crawl, so every consumer shows the change in the same direction.
+type diffResult struct {
+	Old   snapshot
+	New   snapshot
+	Text  string
+	HTML  string
+	Stats changeStats
+	Hunks []hunk
+}
+
+func getDifferences(oldSnapshot snapshot, newSnapshot snapshot, options diffOptions) (diffResult, error) {
+	result := diffResult{Old: oldSnapshot, New: newSnapshot}
 	diff := difflib.UnifiedDiff{
-		A:        difflib.SplitLines(newResponse, true),
-		B:        difflib.SplitLines(oldResponse, true),
-		FromFile: "Old",
-		ToFile:   "Current",
-		Context:  3,
-		Eol:      "\n",
-	}
-	var result differences
+		A:         difflib.SplitLines(oldSnapshot.Content),
+		B:         difflib.SplitLines(newSnapshot.Content),
+		FromFile:  "Old",
+		FromDate:  oldSnapshot.CrawlTime,
+		ToFile:    "Current",
+		ToDate:    newSnapshot.CrawlTime,
+		Context:   options.Context,
+		Eol:       "\n",
+		Algorithm: options.algorithm(),
+	}
 	var err error
 
-	result.text, err = difflib.GetUnifiedDiffString(diff)
+	result.Text, err = difflib.GetUnifiedDiffString(diff)
 	if err != nil {
 		return result, err
 	}
-	if result.text != "" {
-		result.html = "<span>" + strings.Replace(html.EscapeString(result.text), "\n", "<br />", -1) + "</span>"
+	if result.Text == "" {
+		return result, nil
+	}
+
+	algorithm := diff.Algorithm
+	if algorithm == nil {
+		algorithm = difflib.RatcliffObershelp
+	}
+	codes := algorithm.OpCodes(diff.A, diff.B)
+	result.Stats = opCodeStats(codes)
+	result.Hunks = buildHunks(diff.A, diff.B, difflib.GroupOpCodes(codes, options.Context))
+
+	if options.HTML == diffHTMLUnified {
+		result.HTML = "<span>" + highlightDiff(result.Text) + "</span>"
+		return result, nil
+	}
+
+	result.HTML, err = difflib.GetHTMLDiffString(difflib.HTMLDiff{
+		A:          diff.A,
+		B:          diff.B,
+		FromFile:   diff.FromFile,
+		ToFile:     diff.ToFile,
+		Context:    diff.Context,
+		SideBySide: options.HTML == diffHTMLSideBySide,
+		Algorithm:  diff.Algorithm,
+	})
+
+	return result, err
+}
+
+// opCodeStats counts the added and removed lines, replaced lines count as
+// both and the pairs of them as changed
+func opCodeStats(codes []difflib.OpCode) changeStats {
+	var stats changeStats
+	for _, c := range codes {
+		switch c.Tag {
+		case 'r':
+			stats.Removed += c.I2 - c.I1
+			stats.Added += c.J2 - c.J1
+			if c.I2-c.I1 < c.J2-c.J1 {
+				stats.Changed += c.I2 - c.I1
+			} else {
+				stats.Changed += c.J2 - c.J1
+			}
+		case 'd':
+			stats.Removed += c.I2 - c.I1
+		case 'i':
+			stats.Added += c.J2 - c.J1
+		}
+	}
+
+	return stats
+}
+
+// buildHunks turns the grouped opcodes into the hunks of a unified diff
+func buildHunks(a []string, b []string, groups [][]difflib.OpCode) []hunk {
+	var hunks []hunk
+	for _, g := range groups {
+		first, last := g[0], g[len(g)-1]
+		h := hunk{
+			OldStart: first.I1 + 1,
+			OldLines: last.I2 - first.I1,
+			NewStart: first.J1 + 1,
+			NewLines: last.J2 - first.J1,
+		}
+		for _, c := range g {
+			if c.Tag == 'e' {
+				for _, line := range a[c.I1:c.I2] {
+					h.Lines = append(h.Lines, " "+strings.TrimSuffix(line, "\n"))
+				}
+				continue
+			}
+			for _, line := range a[c.I1:c.I2] {
+				h.Lines = append(h.Lines, "-"+strings.TrimSuffix(line, "\n"))
+			}
+			for _, line := range b[c.J1:c.J2] {
+				h.Lines = append(h.Lines, "+"+strings.TrimSuffix(line, "\n"))
+			}
+		}
+		hunks = append(hunks, h)
+	}
+
+	return hunks
+}
+
+// highlightDiff escapes a unified diff for HTML. Removed lines directly
+// followed by added lines are compared word by word and the changed words
+// are highlighted.
+func highlightDiff(text string) string {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		escaped[i] = html.EscapeString(line)
+	}
+
+	for i := 0; i < len(lines); {
+		if !strings.HasPrefix(lines[i], "-") || strings.HasPrefix(lines[i], "--- ") && i == 0 {
+			i++
+			continue
+		}
+
+		removed := i
+		for i < len(lines) && strings.HasPrefix(lines[i], "-") {
+			i++
+		}
+		added := i
+		for i < len(lines) && strings.HasPrefix(lines[i], "+") {
+			i++
+		}
+
+		for k := 0; removed+k < added && added+k < i; k++ {
+			spans := difflib.WordDiff(lines[removed+k][1:], lines[added+k][1:])
+			escaped[removed+k] = "-" + highlightSpans(spans, 'd', "<del style=\"background-color:#ffc0c0\">", "</del>")
+			escaped[added+k] = "+" + highlightSpans(spans, 'i', "<ins style=\"background-color:#c0ffc0;text-decoration:none\">", "</ins>")
+		}
+	}
+
+	return strings.Join(escaped, "<br />") + "<br />"
+}
+
+// highlightSpans renders the side of an inline diff holding the spans tagged
+// with tag, which are wrapped in open and close
+func highlightSpans(spans []difflib.Span, tag byte, open string, close string) string {
+	var b strings.Builder
+	for _, span := range spans {
+		switch span.Tag {
+		case 'e':
+			b.WriteString(html.EscapeString(span.Text))
+		case tag:
+			b.WriteString(open + html.EscapeString(span.Text) + close)
+		}
 	}
 
-	return result, nil
+	return b.String()
 }
 
-func sendEmail(diffs differences, fromEmail string, toEmail string, url string, tlsConfig *tls.Config) error {
+// compareContent reports the differences between the content of two crawls,
+// as a structural diff for JSON and a unified diff otherwise
+func compareContent(w watch, oldSnapshot snapshot, newSnapshot snapshot) (diffResult, error) {
+	if w.Extract.Format != formatJSON {
+		return getDifferences(oldSnapshot, newSnapshot, w.diffOptions())
+	}
+
+	diffs, stats, err := getJSONDifferences(oldSnapshot.Content, newSnapshot.Content, w.Normalize.IgnoreCase)
+
+	return diffResult{Old: oldSnapshot, New: newSnapshot, Text: diffs.text, HTML: diffs.html, Stats: stats}, err
+}
+
+func sendEmail(diffs differences, fromEmail string, toEmail []string, url string, options smtpOptions) error {
 	message := gomail.NewMessage()
 	message.SetHeader("From", fromEmail)
-	message.SetHeader("To", toEmail)
-	message.SetHeader("Subject", "Change detected on URL: " + url)
+	message.SetHeader("To", toEmail...)
+	message.SetHeader("Subject", "Change detected on URL: "+url)
 	message.SetBody("text/html", diffs.html)
 	message.AddAlternative("text/plain", diffs.text)
 
-	mail := gomail.Dialer{Host: "localhost", Port: 587, TLSConfig: tlsConfig}
-	err := mail.DialAndSend(message)
+	client, err := dialSMTP(options)
+	if err != nil {
+		return fmt.Errorf("Unable to send Email: %s", err)
+	}
+
+	sender := &smtpSender{client: client}
+	err = gomail.Send(sender, message)
 	if err != nil {
+		client.Close()
 		return fmt.Errorf("Unable to send Email: %s", err)
 	}
 
-	return nil
+	return sender.Close()
 }
 
-func main() {
-	scanUrl := flag.String("url", "", "URL To Scan")
-	toEmail := flag.String("to", "", "Email to send report to")
-	fromEmail := flag.String("from", "", "Email to send report from")
-	smtpTLSHost := flag.String("tlsHost", "", "Host to match TLS")
+// checkWatch fetches the watched URL, stores the response and reports the
+// differences to the previous crawl to every notifier of the watch
+func checkWatch(ctx context.Context, db *sql.DB, w watch) error {
+	notifiers, err := w.notifiers()
+	if err != nil {
+		return err
+	}
 
-	flag.Parse()
+	var jar http.CookieJar
+	if w.Fetch.Cookies {
+		jar, err = newCookieJar(db, w.URL)
+		if err != nil {
+			return err
+		}
+	}
 
-	if *scanUrl == "" {
-		log.Fatal("Please specify an URL to scan")
+	response, responseCharset, err := getContent(w.URL, w.Fetch, jar)
+	if err != nil {
+		return err
 	}
 
-	if *toEmail == "" {
-		log.Fatal("Please specify a Report email")
+	// A page which no longer matches the extraction settings is still
+	// stored, so the next crawl can tell whether it matches again
+	content, extractErr := processContent(w, string(response))
+	var noMatch noMatchError
+	if extractErr != nil && !errors.As(extractErr, &noMatch) {
+		return extractErr
 	}
 
-	if *fromEmail == "" {
-		log.Fatal("Please specify a Sender email")
+	err = insertRecoredData(db, w.URL, response, responseCharset, content)
+	if err != nil {
+		return err
 	}
 
-	if *smtpTLSHost == "" {
-		log.Fatal("Please specify the TLS SMTP Domain")
+	resultData, err := getLastEntries(db, w.URL)
+	if err != nil {
+		return err
+	}
+
+	if extractErr != nil {
+		return reportNoMatch(ctx, notifiers, w, resultData, extractErr)
 	}
 
-	response, err := getContent(*scanUrl)
+	// A value which can not be found does not keep the diff from being
+	// reported
+	value, err := checkValue(db, w, string(response), content)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("%s: %s", w.Name, err)
+	}
+
+	if len(resultData) < 2 {
+		log.Println("Not enough Data crawled for comparing:", w.Name)
+		return nil
+	} else if len(resultData) != 2 {
+		return fmt.Errorf("We got to much entries from Database: %d", len(resultData))
 	}
 
-	db, err := sql.Open("sqlite3", "file:data.sqlite")
+	// The previous response is processed again, so changed extraction
+	// settings do not show up as a change of the page. If it does not match
+	// the current settings, everything extracted now counts as new.
+	previousContent, err := processContent(w, resultData[1].response)
 	if err != nil {
-		log.Fatal(err)
+		previousContent = ""
 	}
-	defer db.Close()
 
-	err = initializeDB(db)
+	// Masking the lines of JSON would break the document
+	mask := maskContent
+	if w.Extract.Format == formatJSON {
+		mask = maskJSON
+	}
+
+	content, masked, err := mask(content, w.Ignore)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	err = insertRecoredData(db, *scanUrl, response)
+	previousContent, _, err = mask(previousContent, w.Ignore)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	resultData, err := getLastEntries(db, *scanUrl)
+	// Both snapshots are normalized the same way before anything compares
+	// them
+	oldSnapshot := snapshot{Content: normalizeContent(previousContent, w.Normalize), CrawlTime: resultData[1].crawlTime}
+	newSnapshot := snapshot{Content: normalizeContent(content, w.Normalize), CrawlTime: resultData[0].crawlTime}
+	result, err := compareContent(w, oldSnapshot, newSnapshot)
+	if err != nil {
+		return err
+	}
 
-	if len(resultData) < 2 {
-		log.Println("Not enough Data crawled for comparing")
-		return
-	} else if len(resultData) != 2 {
-		log.Fatal("We got to much entries from Database: ", len(resultData))
+	if result.Text == "" && (value == nil || len(value.Conditions) == 0) {
+		return nil
+	}
+
+	triggers, err := evaluateTriggers(w.Triggers, oldSnapshot.Content, newSnapshot.Content)
+	if err != nil {
+		return err
+	}
+
+	return recordChange(ctx, db, notifiers, w, oldSnapshot.Content, newSnapshot.Content, newChange(w, resultData[0].url, result, masked, triggers, value))
+}
+
+// newChange is the change reported to the notifiers for result
+func newChange(w watch, url string, result diffResult, masked int, triggers []triggerEvent, value *valueChange) change {
+	return change{
+		Watch:       w.Name,
+		URL:         url,
+		FromDate:    result.Old.CrawlTime,
+		ToDate:      result.New.CrawlTime,
+		Text:        result.Text,
+		HTML:        result.HTML,
+		Stats:       result.Stats,
+		Hunks:       result.Hunks,
+		Masked:      masked,
+		ContentHash: contentHash(result.New.Content),
+		Triggers:    triggers,
+		Value:       value,
+	}
+}
+
+// reportNoMatch notifies about a selector or XPath expression which stopped
+// matching. Only the first crawl without a match is reported.
+func reportNoMatch(ctx context.Context, notifiers []notifier, w watch, resultData []dbRow, extractErr error) error {
+	if !w.Extract.NotifyOnNoMatch || len(resultData) < 2 {
+		return extractErr
+	}
+
+	_, err := processContent(w, resultData[1].response)
+	if err != nil {
+		return extractErr
 	}
 
-	diffs, err := getDifferences(resultData[1].response, resultData[0].response)
+	text := fmt.Sprintf("%s, the layout of the page has probably changed.\n", extractErr)
+	err = notifyAll(ctx, notifiers, change{
+		Watch:    w.Name,
+		URL:      resultData[0].url,
+		FromDate: resultData[1].crawlTime,
+		ToDate:   resultData[0].crawlTime,
+		Text:     text,
+		HTML:     "<span>" + html.EscapeString(text) + "</span>",
+	})
+	if err != nil {
+		return fmt.Errorf("%s (%s)", extractErr, err)
+	}
+
+	return extractErr
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// checkWatchAndLog is used in daemon mode, where a failing watch must not stop
+// the others
+func checkWatchAndLog(db *sql.DB) func(watch) {
+	return func(w watch) {
+		// Running checks are finished on shutdown, so they do not get the
+		// daemon's context
+		err := checkWatch(context.Background(), db, w)
+		if err != nil {
+			log.Printf("%s: %s", w.Name, err)
+		}
+	}
+}
+
+func main() {
+	// "serve" keeps running and checks every watch on its own schedule,
+	// "history" lists the recorded changes
+	args := os.Args[1:]
+	command := ""
+	if len(args) > 0 && (args[0] == "serve" || args[0] == "history") {
+		command = args[0]
+		args = args[1:]
+	}
+	daemon := command == "serve"
+
+	configFile := flag.String("config", "", "YAML file describing the watches, replaces the other flags")
+	scanUrl := flag.String("url", "", "URL To Scan")
+	toEmail := flag.String("to", "", "Email to send report to")
+	fromEmail := flag.String("from", "", "Email to send report from")
+	smtpTLSHost := flag.String("tlsHost", "", "Host to match TLS")
+	smtpHost := flag.String("smtpHost", "localhost", "SMTP server to send reports with")
+	smtpPort := flag.Int("smtpPort", 587, "Port of the SMTP server")
+	interval := flag.Duration("interval", 0, "Interval between checks in serve mode")
+	limit := flag.Int("limit", 20, "Number of changes the history command lists")
+	showDiff := flag.Bool("diff", false, "Show the diffs in the history")
+
+	flag.CommandLine.Parse(args)
+
+	var cfg config
+	var err error
+	if *configFile != "" {
+		cfg, err = loadConfig(*configFile)
+	} else if command == "history" {
+		cfg = config{Database: defaultDatabase}
+	} else {
+		cfg, err = singleWatchConfig(*scanUrl, *toEmail, *fromEmail, *smtpTLSHost)
+		if err == nil {
+			cfg.Watches[0].Schedule.Interval = *interval
+			cfg.Watches[0].SMTP.Host = *smtpHost
+			cfg.Watches[0].SMTP.Port = *smtpPort
+		}
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if (differences{}) != diffs {
-		err = sendEmail(diffs, *fromEmail, *toEmail, resultData[0].url, &tls.Config{ServerName: *smtpTLSHost})
+	db, err := openDB(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	err = initializeDB(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if command == "history" {
+		err = printHistory(os.Stdout, db, *scanUrl, *limit, *showDiff)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	pool := newWorkerPool(cfg.Workers)
+
+	if daemon {
+		ctx, cancel := context.WithCancel(context.Background())
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+		go func() {
+			<-signals
+			log.Println("Shutting down, waiting for running checks")
+			cancel()
+		}()
+
+		err = serve(ctx, db, cfg.Watches, pool.limit(ctx, checkWatchAndLog(db)))
 		if err != nil {
 			log.Fatal(err)
 		}
+		return
+	}
+
+	var mutex sync.Mutex
+	failed := 0
+	pool.runAll(cfg.Watches, func(w watch) {
+		err := checkWatch(context.Background(), db, w)
+		if err != nil {
+			log.Printf("%s: %s", w.Name, err)
+			mutex.Lock()
+			failed++
+			mutex.Unlock()
+		}
+	})
+
+	if failed > 0 {
+		log.Fatalf("%d of %d watches failed", failed, len(cfg.Watches))
 	}
 }
@@ -1,18 +1,21 @@
 package main
 
 import (
+	"crypto/hmac"
+	"crypto/md5"
 	"crypto/tls"
-	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"github.com/labstack/gommon/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 	"io"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -63,14 +66,14 @@ func TestGetContentSuccess(t *testing.T) {
 	}))
 	defer func() { testServer.Close() }()
 
-	response, err := getContent(testServer.URL)
+	response, _, err := getContent(testServer.URL, fetchOptions{}, nil)
 	require.NoError(t, err, "Expected no error")
 	assert.Equal(t, htmlBody, response)
 }
 
 func TestGetContentRequestError(t *testing.T) {
 	invalidURL := "http:// test.com"
-	response, err := getContent(invalidURL)
+	response, _, err := getContent(invalidURL, fetchOptions{}, nil)
 	expectedError := "parse \"" + invalidURL + "\": invalid character \" \" in host name"
 	assert.Equal(t, expectedError, err.Error())
 	assert.Nil(t, response)
@@ -79,7 +82,7 @@ func TestGetContentRequestError(t *testing.T) {
 
 func TestGetContentURLError(t *testing.T) {
 	invalidURL := "test.com"
-	response, err := getContent(invalidURL)
+	response, _, err := getContent(invalidURL, fetchOptions{}, nil)
 	expectedError := "Error getting Response: Get \"" + invalidURL + "\": unsupported protocol scheme \"\""
 	assert.Equal(t, expectedError, err.Error())
 	assert.Nil(t, response)
@@ -92,7 +95,7 @@ func TestGetContentStatusError(t *testing.T) {
 	}))
 	defer func() { testServer.Close() }()
 
-	response, err := getContent(testServer.URL)
+	response, _, err := getContent(testServer.URL, fetchOptions{}, nil)
 	expectedError := "Incorrect HTTP Status Code: 404 Not Found"
 	assert.Equal(t, expectedError, err.Error())
 	assert.Nil(t, response)
@@ -106,6 +109,13 @@ func TestInitializeDB(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectExec("CREATE TABLE IF NOT EXISTS responseData \\(url text, crawlTime text, response text\\);").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM pragma_table_info").WithArgs("responseData", "content").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("ALTER TABLE responseData ADD COLUMN content text").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM pragma_table_info").WithArgs("responseData", "charset").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("ALTER TABLE responseData ADD COLUMN charset text").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS changeLog \\(url text, fromDate text, toDate text, lines integer, chars integer, ratio real, significant integer, diff text\\);").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS valueHistory \\(url text, crawlTime text, value real\\);").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS cookies \\(watch text, url text, domain text, path text, name text, cookie text\\);").WillReturnResult(sqlmock.NewResult(0, 0))
 
 	err = initializeDB(db)
 	require.NoError(t, err, "Expected no error")
@@ -116,6 +126,48 @@ func TestInitializeDB(t *testing.T) {
 	}
 }
 
+func TestInitializeDBExistingColumn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS responseData").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM pragma_table_info").WithArgs("responseData", "content").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM pragma_table_info").WithArgs("responseData", "charset").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS changeLog").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS valueHistory").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS cookies").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = initializeDB(db)
+	require.NoError(t, err, "Expected no error")
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInitializeDBMigration(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "old.sqlite"))
+	require.NoError(t, err, "Expected no error")
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE responseData (url text, crawlTime text, response text)")
+	require.NoError(t, err, "Expected no error")
+
+	err = initializeDB(db)
+	require.NoError(t, err, "Expected no error")
+
+	// Running it again must not try to add the column twice
+	err = initializeDB(db)
+	require.NoError(t, err, "Expected no error")
+
+	err = insertRecoredData(db, "http://www.test.com", htmlBody, "utf-8", "<h1>This is a heading</h1>")
+	require.NoError(t, err, "Expected no error")
+}
+
 func TestInitializeDBError(t *testing.T) {
 	db, _, err := sqlmock.New()
 	if err != nil {
@@ -139,11 +191,11 @@ func TestInsertRecoredData(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectPrepare("INSERT INTO responseData")
 	mock.ExpectExec("INSERT INTO responseData").
-		WithArgs(scanUrl, fmt.Sprintf("%s", htmlBody)).
+		WithArgs(scanUrl, fmt.Sprintf("%s", htmlBody), "utf-8", "<h1>This is a heading</h1>").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	err = insertRecoredData(db, scanUrl, htmlBody)
+	err = insertRecoredData(db, scanUrl, htmlBody, "utf-8", "<h1>This is a heading</h1>")
 	require.NoError(t, err, "Expected no error")
 
 	err = mock.ExpectationsWereMet()
@@ -162,7 +214,7 @@ func TestInsertRecoredBeginError(t *testing.T) {
 	scanUrl := "http://www.test.com"
 
 	// Test 'BEGIN' error
-	err = insertRecoredData(db, scanUrl, htmlBody)
+	err = insertRecoredData(db, scanUrl, htmlBody, "utf-8", "<h1>This is a heading</h1>")
 	assert.Equal(t, "all expectations were already fulfilled, call to database transaction Begin was not expected", err.Error())
 }
 
@@ -177,8 +229,8 @@ func TestInsertRecoredPrepareError(t *testing.T) {
 
 	// Test 'PREPARE' error
 	mock.ExpectBegin()
-	err = insertRecoredData(db, scanUrl, htmlBody)
-	assert.Equal(t, "all expectations were already fulfilled, call to Prepare 'INSERT INTO responseData(url, crawlTime, response) values(?, datetime('now'), ?)' query was not expected", err.Error())
+	err = insertRecoredData(db, scanUrl, htmlBody, "utf-8", "<h1>This is a heading</h1>")
+	assert.Equal(t, "all expectations were already fulfilled, call to Prepare 'INSERT INTO responseData(url, crawlTime, response, charset, content) values(?, datetime('now'), ?, ?, ?)' query was not expected", err.Error())
 }
 
 func TestInsertRecoredInsertError(t *testing.T) {
@@ -195,8 +247,8 @@ func TestInsertRecoredInsertError(t *testing.T) {
 	mock.ExpectPrepare("INSERT INTO responseData")
 	mock.ExpectRollback()
 
-	err = insertRecoredData(db, scanUrl, htmlBody)
-	assert.Equal(t, "call to ExecQuery 'INSERT INTO responseData(url, crawlTime, response) values(?, datetime('now'), ?)' with args [{Name: Ordinal:1 Value:http://www.test.com} {Name: Ordinal:2 Value:<!DOCTYPE html>\n\t<html>\n\t<head>\n\t<link rel=\"stylesheet\" href=\"styles.css\">\n\t</head>\n\t<body>\n\n\t<h1>This is a heading</h1>\n\t<p>This is a paragraph.</p>\n\n\t</body>\n\t</html>}], was not expected, next expectation is: ExpectedRollback => expecting transaction Rollback", err.Error())
+	err = insertRecoredData(db, scanUrl, htmlBody, "utf-8", "<h1>This is a heading</h1>")
+	assert.Equal(t, "call to ExecQuery 'INSERT INTO responseData(url, crawlTime, response, charset, content) values(?, datetime('now'), ?, ?, ?)' with args [{Name: Ordinal:1 Value:http://www.test.com} {Name: Ordinal:2 Value:<!DOCTYPE html>\n\t<html>\n\t<head>\n\t<link rel=\"stylesheet\" href=\"styles.css\">\n\t</head>\n\t<body>\n\n\t<h1>This is a heading</h1>\n\t<p>This is a paragraph.</p>\n\n\t</body>\n\t</html>} {Name: Ordinal:3 Value:utf-8} {Name: Ordinal:4 Value:<h1>This is a heading</h1>}], was not expected, next expectation is: ExpectedRollback => expecting transaction Rollback", err.Error())
 
 	// Make sure 'Rollback' was executed!
 	err = mock.ExpectationsWereMet()
@@ -227,7 +279,7 @@ func TestGetLastEntries(t *testing.T) {
 		response: fmt.Sprintf("%s - 1", htmlBody),
 	})
 
-	mock.ExpectQuery(`SELECT url, datetime\(crawlTime\) AS crawlTime, response FROM responseData WHERE url = \? ORDER BY crawlTime DESC LIMIT 2`).
+	mock.ExpectQuery(`SELECT url, datetime\(crawlTime\) AS crawlTime, response FROM responseData WHERE url = \? ORDER BY crawlTime DESC, rowid DESC LIMIT 2`).
 		WithArgs(requestURL).
 		WillReturnRows(sqlmock.NewRows(columns).
 			AddRow(expectedResult[0].url, expectedResult[0].crawlTime, expectedResult[0].response).
@@ -238,45 +290,119 @@ func TestGetLastEntries(t *testing.T) {
 	assert.Equal(t, expectedResult, resultData)
 }
 
+// normalizedSnapshot normalizes body like a watch with the default settings
+func normalizedSnapshot(body []byte) snapshot {
+	return snapshot{Content: normalizeContent(string(body), defaultNormalizeOptions())}
+}
+
 func TestGetDifferencesEqual(t *testing.T) {
-	diffs, err := getDifferences(fmt.Sprintf("%s", htmlBody), fmt.Sprintf("%s", htmlBody))
+	diffs, err := getDifferences(normalizedSnapshot(htmlBody), normalizedSnapshot(htmlBody), diffOptions{Context: 3})
 	require.NoError(t, err, "Expected no error")
-	assert.Equal(t, differences{}, diffs)
+	assert.Equal(t, diffResult{Old: normalizedSnapshot(htmlBody), New: normalizedSnapshot(htmlBody)}, diffs)
 }
 
 func TestGetDifferencesEqualError(t *testing.T) {
-	diffs, err := getDifferences(fmt.Sprintf("%s", htmlBody), fmt.Sprintf("%s", htmlBody))
+	diffs, err := getDifferences(normalizedSnapshot(htmlBody), normalizedSnapshot(htmlBody), diffOptions{Context: 3})
 	require.NoError(t, err, "Expected no error")
-	assert.Equal(t, differences{}, diffs)
+	assert.Equal(t, "", diffs.Text)
+	assert.Equal(t, "", diffs.HTML)
 }
 
 func TestGetDifferencesNotEqual(t *testing.T) {
-	diffs, err := getDifferences(fmt.Sprintf("%s", htmlBody), fmt.Sprintf("%s", htmlBodyNew))
+	diffs, err := getDifferences(normalizedSnapshot(htmlBody), normalizedSnapshot(htmlBodyNew), diffOptions{Context: 3, HTML: diffHTMLUnified})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "--- Old\n+++ Current\n@@ -5,8 +5,8 @@\n </head>\n <body>\n \n-<h1>This is a heading</h1>\n-<p>This is a paragraph.</p>\n+<h1>This is a new heading</h1>\n+<p>This is a new paragraph.</p>\n \n </body>\n </html>\n", diffs.Text)
+	assert.Equal(t, "<span>--- Old<br />+++ Current<br />@@ -5,8 +5,8 @@<br /> &lt;/head&gt;<br /> &lt;body&gt;<br /> <br />-&lt;h1&gt;This is a heading&lt;/h1&gt;<br />-&lt;p&gt;This is a paragraph.&lt;/p&gt;<br />+&lt;h1&gt;This is a <ins style=\"background-color:#c0ffc0;text-decoration:none\">new </ins>heading&lt;/h1&gt;<br />+&lt;p&gt;This is a <ins style=\"background-color:#c0ffc0;text-decoration:none\">new </ins>paragraph.&lt;/p&gt;<br /> <br /> &lt;/body&gt;<br /> &lt;/html&gt;<br /></span>", diffs.HTML)
+	assert.Equal(t, changeStats{Added: 2, Removed: 2, Changed: 2}, diffs.Stats)
+	require.Len(t, diffs.Hunks, 1)
+	assert.Equal(t, hunk{OldStart: 5, OldLines: 8, NewStart: 5, NewLines: 8, Lines: []string{
+		" </head>", " <body>", " ", "-<h1>This is a heading</h1>", "-<p>This is a paragraph.</p>",
+		"+<h1>This is a new heading</h1>", "+<p>This is a new paragraph.</p>", " ", " </body>", " </html>",
+	}}, diffs.Hunks[0])
+}
+
+func TestGetDifferencesHTMLViews(t *testing.T) {
+	diffs, err := getDifferences(normalizedSnapshot(htmlBody), normalizedSnapshot(htmlBodyNew), diffOptions{Context: 1})
+	require.NoError(t, err, "Expected no error")
+	assert.True(t, strings.HasPrefix(diffs.HTML, "<table style="))
+	assert.Contains(t, diffs.HTML, "This is a <ins style=")
+	assert.Contains(t, diffs.HTML, "6 unchanged lines")
+	assert.NotContains(t, diffs.HTML, "colspan=\"4\"")
+
+	diffs, err = getDifferences(normalizedSnapshot(htmlBody), normalizedSnapshot(htmlBodyNew), diffOptions{Context: 1, HTML: diffHTMLSideBySide})
 	require.NoError(t, err, "Expected no error")
-	assert.Equal(t,
-		differences{
-			text:"--- Old\n+++ Current\n@@ -5,8 +5,8 @@\n </head>\n <body>\n \n-<h1>This is a heading</h1>\n-<p>This is a paragraph.</p>\n+<h1>This is a new heading</h1>\n+<p>This is a new paragraph.</p>\n \n </body>\n </html>\n",
-			html:"<span>--- Old<br />+++ Current<br />@@ -5,8 +5,8 @@<br /> &lt;/head&gt;<br /> &lt;body&gt;<br /> <br />-&lt;h1&gt;This is a heading&lt;/h1&gt;<br />-&lt;p&gt;This is a paragraph.&lt;/p&gt;<br />+&lt;h1&gt;This is a new heading&lt;/h1&gt;<br />+&lt;p&gt;This is a new paragraph.&lt;/p&gt;<br /> <br /> &lt;/body&gt;<br /> &lt;/html&gt;<br /></span>"},
-		diffs)
+	assert.Contains(t, diffs.HTML, "colspan=\"4\"")
+	assert.Contains(t, diffs.HTML, "<th colspan=\"2\"")
+}
+
+func TestGetDifferencesAlgorithms(t *testing.T) {
+	expected, err := getDifferences(normalizedSnapshot(htmlBody), normalizedSnapshot(htmlBodyNew), diffOptions{Context: 3, HTML: diffHTMLUnified})
+	require.NoError(t, err, "Expected no error")
+
+	for algorithm := range diffAlgorithms {
+		diffs, err := getDifferences(normalizedSnapshot(htmlBody), normalizedSnapshot(htmlBodyNew), diffOptions{Context: 3, HTML: diffHTMLUnified, Algorithm: algorithm})
+		require.NoError(t, err, "Expected no error")
+		assert.Equal(t, expected, diffs, algorithm)
+	}
+}
+
+func TestHighlightDiff(t *testing.T) {
+	text := "--- Old\n+++ Current\n@@ -1,3 +1,3 @@\n-<b>Price:</b> 10 €\n-gone\n+<b>Price:</b> 12 €\n same\n"
+	assert.Equal(t, "--- Old<br />+++ Current<br />@@ -1,3 +1,3 @@<br />"+
+		"-&lt;b&gt;Price:&lt;/b&gt; <del style=\"background-color:#ffc0c0\">10</del> €<br />"+
+		"-gone<br />"+
+		"+&lt;b&gt;Price:&lt;/b&gt; <ins style=\"background-color:#c0ffc0;text-decoration:none\">12</ins> €<br />"+
+		" same<br />", highlightDiff(text))
 }
 
 func TestGetDifferencesWithSpacesEqual(t *testing.T) {
-	diffs, err := getDifferences(fmt.Sprintf("%s", htmlBody), fmt.Sprintf(" %s ", htmlBodyNewSpaces))
+	diffs, err := getDifferences(normalizedSnapshot(htmlBody), normalizedSnapshot([]byte(fmt.Sprintf(" %s ", htmlBodyNewSpaces))), diffOptions{Context: 3})
 	require.NoError(t, err, "Expected no error")
-	assert.Equal(t,
-		differences{
-			text:"",
-			html:""},
-		diffs)
+	assert.Equal(t, "", diffs.Text)
+	assert.Equal(t, "", diffs.HTML)
+}
+
+func TestGetDifferencesCrawlTimes(t *testing.T) {
+	diffs, err := getDifferences(snapshot{Content: "a\nb", CrawlTime: "2026-01-01 10:00:00"}, snapshot{Content: "a\nc", CrawlTime: "2026-01-01 11:00:00"}, diffOptions{Context: 3})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "--- Old\t2026-01-01 10:00:00\n+++ Current\t2026-01-01 11:00:00\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n", diffs.Text)
+	assert.Equal(t, changeStats{Added: 1, Removed: 1, Changed: 1}, diffs.Stats)
+}
+
+// fakeSMTPOptions selects what the fake SMTP server offers to the client
+type fakeSMTPOptions struct {
+	implicitTLS bool
+	noSTARTTLS  bool
+	// auth is the mechanism the client has to log in with, empty for none
+	auth     string
+	username string
+	password string
+}
+
+func fakeSMTPTLSConfig() *tls.Config {
+	cert, err := tls.LoadX509KeyPair("testdata/testdomain.com/cert.pem", "testdata/testdomain.com/key.pem")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return &tls.Config{Certificates: []tls.Certificate{cert}}
 }
 
-func startFakeSMTPServer(t *testing.T, fromEmail string, toEmail string, parseURL string) {
-	listener, err := net.Listen("tcp", ":587")
+// startFakeSMTPServer starts a server for a single connection and returns the
+// port it listens on
+func startFakeSMTPServer(t *testing.T, fromEmail string, toEmail string, parseURL string, options fakeSMTPOptions) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	assert.NoError(t, err, "Expected no error on creating Listener")
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	if options.implicitTLS {
+		listener = tls.NewListener(listener, fakeSMTPTLSConfig())
+	}
 
 	go func() {
 		conn, err := listener.Accept()
 		assert.NoError(t, err, "Expected no error on starting Listener")
+		listener.Close()
 
 		go func(conn net.Conn) {
 			conn.Write([]byte("220 testdomain.com ESMTP\n"))
@@ -304,9 +430,11 @@ func startFakeSMTPServer(t *testing.T, fromEmail string, toEmail string, parseUR
 
 				switch command {
 				case "EHLO":
-					fakeSMTPEHLOCommand(t, conn, clientString)
+					fakeSMTPEHLOCommand(t, conn, clientString, options)
 				case "STARTTLS":
 					conn = fakeSMTPSTARTTLSCommand(t, conn, clientString)
+				case "AUTH":
+					fakeSMTPAUTHCommand(t, conn, clientString, options)
 				case "MAIL":
 					fakeSMTPMAILCommand(t, conn, clientString, fromEmail)
 				case "RCPT":
@@ -322,25 +450,66 @@ func startFakeSMTPServer(t *testing.T, fromEmail string, toEmail string, parseUR
 		}(conn)
 
 	}()
+
+	return port
 }
 
-func fakeSMTPEHLOCommand(t *testing.T, conn net.Conn, clientString string) {
+func fakeSMTPEHLOCommand(t *testing.T, conn net.Conn, clientString string, options fakeSMTPOptions) {
 	assert.Equal(t, "EHLO localhost\r\n", clientString)
-	conn.Write([]byte("250-testdomain.com\n250-PIPELINING\n250-SIZE 209715200\n250-VRFY\n250-ETRN\n250-STARTTLS\n250-ENHANCEDSTATUSCODES\n250-8BITMIME\n250 DSN\n"))
+	extensions := "250-testdomain.com\n250-PIPELINING\n250-SIZE 209715200\n250-VRFY\n250-ETRN\n"
+	if !options.noSTARTTLS {
+		extensions += "250-STARTTLS\n"
+	}
+	if options.auth != "" {
+		extensions += "250-AUTH PLAIN LOGIN CRAM-MD5\n"
+	}
+	conn.Write([]byte(extensions + "250-ENHANCEDSTATUSCODES\n250-8BITMIME\n250 DSN\n"))
 }
 
 func fakeSMTPSTARTTLSCommand(t *testing.T, conn net.Conn, clientString string) net.Conn {
 	assert.Equal(t, "STARTTLS\r\n", clientString)
 	conn.Write([]byte("220 2.0.0 Ready to start TLS\n"))
-	var tlsConn *tls.Conn
-	cert, err := tls.LoadX509KeyPair("testdata/testdomain.com/cert.pem", "testdata/testdomain.com/key.pem")
-	if err != nil {
-		log.Fatal(err)
+
+	return net.Conn(tls.Server(conn, fakeSMTPTLSConfig()))
+}
+
+// fakeSMTPReadLine reads the next client line during an AUTH exchange
+func fakeSMTPReadLine(t *testing.T, conn net.Conn) string {
+	buf := make([]byte, 1024)
+	_, err := conn.Read(buf)
+	assert.NoError(t, err, "Expected no error reading AUTH response")
+
+	return strings.Trim(string(buf), "\x00\r\n")
+}
+
+func fakeSMTPDecode(t *testing.T, encoded string) string {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	assert.NoError(t, err, "Expected base64 encoded AUTH response")
+
+	return string(decoded)
+}
+
+func fakeSMTPAUTHCommand(t *testing.T, conn net.Conn, clientString string, options fakeSMTPOptions) {
+	fields := strings.Fields(clientString)
+	assert.Equal(t, options.auth, fields[1], "Unexpected AUTH mechanism")
+
+	switch fields[1] {
+	case "PLAIN":
+		assert.Equal(t, "\x00"+options.username+"\x00"+options.password, fakeSMTPDecode(t, fields[2]))
+	case "LOGIN":
+		conn.Write([]byte("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")) + "\n"))
+		assert.Equal(t, options.username, fakeSMTPDecode(t, fakeSMTPReadLine(t, conn)))
+		conn.Write([]byte("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")) + "\n"))
+		assert.Equal(t, options.password, fakeSMTPDecode(t, fakeSMTPReadLine(t, conn)))
+	case "CRAM-MD5":
+		challenge := "<12345.67890@testdomain.com>"
+		conn.Write([]byte("334 " + base64.StdEncoding.EncodeToString([]byte(challenge)) + "\n"))
+		mac := hmac.New(md5.New, []byte(options.password))
+		mac.Write([]byte(challenge))
+		assert.Equal(t, options.username+" "+hex.EncodeToString(mac.Sum(nil)), fakeSMTPDecode(t, fakeSMTPReadLine(t, conn)))
 	}
-	cfg := &tls.Config{Certificates: []tls.Certificate{cert}}
 
-	tlsConn = tls.Server(conn, cfg)
-	return net.Conn(tlsConn)
+	conn.Write([]byte("235 2.7.0 Authentication successful\n"))
 }
 
 func fakeSMTPMAILCommand(t *testing.T, conn net.Conn, clientString string, fromEmail string) {
@@ -388,22 +557,82 @@ func fakeSMTPQUITCommand(t *testing.T, conn net.Conn, clientString string) {
 	conn.Close()
 }
 
-func TestSendEmail(t *testing.T) {
-	diff := differences{
-		text: "--- Old\n+++ Current\n@@ -5,8 +5,8 @@\n </head>\n <body>\n \n-<h1>This is a heading</h1>\n-<p>This is a paragraph.</p>\n+<h1>This is a new heading</h1>\n+<p>This is a new paragraph.</p>\n \n </body>\n </html>\n",
-		html: "<span>--- Old<br />+++ Current<br />@@ -5,8 +5,8 @@<br /> &lt;/head&gt;<br /> &lt;body&gt;<br /> <br />-&lt;h1&gt;This is a heading&lt;/h1&gt;<br />-&lt;p&gt;This is a paragraph.&lt;/p&gt;<br />+&lt;h1&gt;This is a new heading&lt;/h1&gt;<br />+&lt;p&gt;This is a new paragraph.&lt;/p&gt;<br /> <br /> &lt;/body&gt;<br /> &lt;/html&gt;<br /></span>",
-	}
+var sendEmailDiff = differences{
+	text: "--- Old\n+++ Current\n@@ -5,8 +5,8 @@\n </head>\n <body>\n \n-<h1>This is a heading</h1>\n-<p>This is a paragraph.</p>\n+<h1>This is a new heading</h1>\n+<p>This is a new paragraph.</p>\n \n </body>\n </html>\n",
+	html: "<span>--- Old<br />+++ Current<br />@@ -5,8 +5,8 @@<br /> &lt;/head&gt;<br /> &lt;body&gt;<br /> <br />-&lt;h1&gt;This is a heading&lt;/h1&gt;<br />-&lt;p&gt;This is a paragraph.&lt;/p&gt;<br />+&lt;h1&gt;This is a new heading&lt;/h1&gt;<br />+&lt;p&gt;This is a new paragraph.&lt;/p&gt;<br /> <br /> &lt;/body&gt;<br /> &lt;/html&gt;<br /></span>",
+}
 
+func TestSendEmail(t *testing.T) {
 	// Fake SMTP Server
-	startFakeSMTPServer(t, "from@test.com", "to@test.com", "https://www.test.com")
+	port := startFakeSMTPServer(t, "from@test.com", "to@test.com", "https://www.test.com", fakeSMTPOptions{})
 
-	CA_Pool := x509.NewCertPool()
-	serverCert, err := ioutil.ReadFile("./testdata/cert.pem")
-	if err != nil {
-		log.Fatal("Could not load root ca!")
-	}
-	CA_Pool.AppendCertsFromPEM(serverCert)
-	tlsConfig := tls.Config{RootCAs: CA_Pool, ServerName: "testdomain.com"}
+	options := smtpOptions{Host: "127.0.0.1", Port: port, Encryption: encryptionSTARTTLS, TLSHost: "testdomain.com", CAFile: "./testdata/cert.pem"}
+	err := sendEmail(sendEmailDiff, "from@test.com", []string{"to@test.com"}, "https://www.test.com", options)
+	require.NoError(t, err, "Expected no error")
+}
+
+func TestSendEmailImplicitTLSWithPlainAuth(t *testing.T) {
+	port := startFakeSMTPServer(t, "from@test.com", "to@test.com", "https://www.test.com", fakeSMTPOptions{implicitTLS: true, auth: "PLAIN", username: "user", password: "secret"})
+
+	options := smtpOptions{Host: "127.0.0.1", Port: port, Encryption: "TLS", TLSHost: "testdomain.com", CAFile: "./testdata/cert.pem", Username: "user", Password: "secret", Auth: authPlain}
+	err := sendEmail(sendEmailDiff, "from@test.com", []string{"to@test.com"}, "https://www.test.com", options)
+	require.NoError(t, err, "Expected no error")
+}
+
+func TestSendEmailUnencryptedWithLoginAuth(t *testing.T) {
+	port := startFakeSMTPServer(t, "from@test.com", "to@test.com", "https://www.test.com", fakeSMTPOptions{noSTARTTLS: true, auth: "LOGIN", username: "user", password: "secret"})
+
+	options := smtpOptions{Host: "127.0.0.1", Port: port, Encryption: encryptionNone, Username: "user", Password: "secret", Auth: authLogin}
+	err := sendEmail(sendEmailDiff, "from@test.com", []string{"to@test.com"}, "https://www.test.com", options)
+	require.NoError(t, err, "Expected no error")
+}
+
+func TestSendEmailSTARTTLSWithCRAMMD5Auth(t *testing.T) {
+	port := startFakeSMTPServer(t, "from@test.com", "to@test.com", "https://www.test.com", fakeSMTPOptions{auth: "CRAM-MD5", username: "user", password: "secret"})
+
+	options := smtpOptions{Host: "127.0.0.1", Port: port, Encryption: encryptionSTARTTLS, TLSHost: "testdomain.com", CAFile: "./testdata/cert.pem", Username: "user", Password: "secret", Auth: authCRAMMD5}
+	err := sendEmail(sendEmailDiff, "from@test.com", []string{"to@test.com"}, "https://www.test.com", options)
+	require.NoError(t, err, "Expected no error")
+}
+
+func TestSendEmailSkipVerify(t *testing.T) {
+	port := startFakeSMTPServer(t, "from@test.com", "to@test.com", "https://www.test.com", fakeSMTPOptions{implicitTLS: true})
+
+	options := smtpOptions{Host: "127.0.0.1", Port: port, Encryption: encryptionTLS, InsecureSkipVerify: true}
+	err := sendEmail(sendEmailDiff, "from@test.com", []string{"to@test.com"}, "https://www.test.com", options)
+	require.NoError(t, err, "Expected no error")
+}
+
+func TestSendEmailPinnedCertificate(t *testing.T) {
+	port := startFakeSMTPServer(t, "from@test.com", "to@test.com", "https://www.test.com", fakeSMTPOptions{})
+
+	// Pinning works without trusting the CA and without a matching host name
+	options := smtpOptions{Host: "127.0.0.1", Port: port, Encryption: encryptionSTARTTLS, Fingerprint: "13:21:09:63:D4:25:47:04:C9:4E:A4:4D:3B:AB:0A:05:90:30:63:4D:D7:39:1D:BA:1E:D7:D9:A7:6A:AD:60:A0"}
+	err := sendEmail(sendEmailDiff, "from@test.com", []string{"to@test.com"}, "https://www.test.com", options)
+	require.NoError(t, err, "Expected no error")
+}
+
+func TestSendEmailWrongPinnedCertificate(t *testing.T) {
+	port := startFakeSMTPServer(t, "from@test.com", "to@test.com", "https://www.test.com", fakeSMTPOptions{implicitTLS: true})
+
+	options := smtpOptions{Host: "127.0.0.1", Port: port, Encryption: encryptionTLS, Fingerprint: "00"}
+	err := sendEmail(sendEmailDiff, "from@test.com", []string{"to@test.com"}, "https://www.test.com", options)
+	assert.Contains(t, err.Error(), "Server certificate fingerprint does not match")
+}
+
+func TestSendEmailUntrustedCertificate(t *testing.T) {
+	port := startFakeSMTPServer(t, "from@test.com", "to@test.com", "https://www.test.com", fakeSMTPOptions{})
+
+	options := smtpOptions{Host: "127.0.0.1", Port: port, Encryption: encryptionSTARTTLS, TLSHost: "testdomain.com"}
+	err := sendEmail(sendEmailDiff, "from@test.com", []string{"to@test.com"}, "https://www.test.com", options)
+	assert.Contains(t, err.Error(), "Unable to send Email: ")
+	assert.Contains(t, err.Error(), "certificate")
+}
+
+func TestSendEmailMissingSTARTTLS(t *testing.T) {
+	port := startFakeSMTPServer(t, "from@test.com", "to@test.com", "https://www.test.com", fakeSMTPOptions{noSTARTTLS: true})
 
-	sendEmail(diff, "from@test.com", "to@test.com", "https://www.test.com", &tlsConfig)
+	options := smtpOptions{Host: "127.0.0.1", Port: port, Encryption: encryptionSTARTTLS}
+	err := sendEmail(sendEmailDiff, "from@test.com", []string{"to@test.com"}, "https://www.test.com", options)
+	assert.Equal(t, "Unable to send Email: Server does not support STARTTLS", err.Error())
 }
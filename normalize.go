@@ -0,0 +1,92 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"WD/difflib"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var whitespaceRun = regexp.MustCompile(`\s+`)
+
+// normalizeOptions remove differences between two crawls which do not
+// matter, they are applied to both snapshots before comparing them
+type normalizeOptions struct {
+	// Trim removes the whitespace around every line and the blank lines
+	// around the content, on by default
+	Trim bool `yaml:"trim"`
+	// CollapseWhitespace replaces every run of whitespace within a line by
+	// a single space
+	CollapseWhitespace bool `yaml:"collapseWhitespace"`
+	// IgnoreBlankLines drops lines which only hold whitespace
+	IgnoreBlankLines bool `yaml:"ignoreBlankLines"`
+	// IgnoreCase compares the content in lower case, the diff still shows
+	// the original text
+	IgnoreCase bool `yaml:"ignoreCase"`
+	// NFC brings the content into Unicode normalization form C, so composed
+	// and decomposed characters are equal
+	NFC bool `yaml:"nfc"`
+	// LineEndings turns "\r\n" and "\r" into "\n", on by default
+	LineEndings bool `yaml:"lineEndings"`
+}
+
+func defaultNormalizeOptions() normalizeOptions {
+	return normalizeOptions{Trim: true, LineEndings: true}
+}
+
+// normalizeContent applies options to content
+func normalizeContent(content string, options normalizeOptions) string {
+	if options.LineEndings {
+		content = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(content)
+	}
+
+	if options.NFC {
+		content = norm.NFC.String(content)
+	}
+
+	if !options.Trim && !options.CollapseWhitespace && !options.IgnoreBlankLines {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if options.IgnoreBlankLines && strings.TrimSpace(line) == "" {
+			continue
+		}
+		if options.Trim {
+			line = strings.TrimSpace(line)
+		}
+		if options.CollapseWhitespace {
+			line = whitespaceRun.ReplaceAllString(line, " ")
+		}
+		kept = append(kept, line)
+	}
+
+	content = strings.Join(kept, "\n")
+	if options.Trim {
+		content = strings.Trim(content, "\n")
+	}
+
+	return content
+}
+
+// foldCase matches up lines like algorithm while ignoring their case
+type foldCase struct {
+	algorithm difflib.Algorithm
+}
+
+func (f foldCase) OpCodes(a []string, b []string) []difflib.OpCode {
+	return f.algorithm.OpCodes(lowerLines(a), lowerLines(b))
+}
+
+func lowerLines(lines []string) []string {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = strings.ToLower(line)
+	}
+
+	return result
+}
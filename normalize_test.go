@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeContent(t *testing.T) {
+	content := "  <p>Hello   World</p>\r\n\r\n\t<P>Caf\u00e9</P>  \r"
+
+	assert.Equal(t, content, normalizeContent(content, normalizeOptions{}))
+	assert.Equal(t, "<p>Hello   World</p>\n\n<P>Caf\u00e9</P>", normalizeContent(content, defaultNormalizeOptions()))
+	assert.Equal(t, "  <p>Hello   World</p>\n\n\t<P>Caf\u00e9</P>  \n", normalizeContent(content, normalizeOptions{LineEndings: true}))
+	assert.Equal(t, " <p>Hello World</p> \n \n <P>Caf\u00e9</P> ", normalizeContent(content, normalizeOptions{CollapseWhitespace: true}))
+	assert.Equal(t, "  <p>Hello   World</p>\n\t<P>Caf\u00e9</P>  ", normalizeContent(content, normalizeOptions{LineEndings: true, IgnoreBlankLines: true}))
+
+	all := normalizeOptions{Trim: true, CollapseWhitespace: true, IgnoreBlankLines: true, IgnoreCase: true, NFC: true, LineEndings: true}
+	// The case is only ignored when comparing, see TestGetDifferencesIgnoreCase
+	assert.Equal(t, "<p>Hello World</p>\n<P>Caf\u00e9</P>", normalizeContent(content, all))
+
+	// Composed and decomposed characters only compare equal with NFC
+	assert.Equal(t, "cafe\u0301", normalizeContent("cafe\u0301", normalizeOptions{}))
+	assert.Equal(t, "caf\u00e9", normalizeContent("cafe\u0301", normalizeOptions{NFC: true}))
+}
+
+func TestGetDifferencesIgnoreCase(t *testing.T) {
+	options := diffOptions{Context: 3, ignoreCase: true}
+	diffs, err := getDifferences(snapshot{Content: "Price: 10 EUR\nStock"}, snapshot{Content: "PRICE: 10 eur\nStock"}, options)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "", diffs.Text)
+
+	diffs, err = getDifferences(snapshot{Content: "Price: 10 EUR\nStock"}, snapshot{Content: "PRICE: 12 EUR\nstock"}, options)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "--- Old\n+++ Current\n@@ -1,2 +1,2 @@\n-Price: 10 EUR\n+PRICE: 12 EUR\n Stock\n", diffs.Text)
+	assert.Contains(t, diffs.HTML, "EUR")
+	assert.NotContains(t, diffs.HTML, "eur")
+	assert.Equal(t, changeStats{Added: 1, Removed: 1, Changed: 1}, diffs.Stats)
+
+	options.Algorithm = "histogram"
+	diffs, err = getDifferences(snapshot{Content: "Price: 10 EUR\nStock"}, snapshot{Content: "PRICE: 10 eur\nStock"}, options)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "", diffs.Text)
+
+	w := defaultWatch()
+	w.Normalize.IgnoreCase = true
+	result, err := compareContent(w, snapshot{Content: "Price: 10 EUR"}, snapshot{Content: "price: 10 eur"})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "", result.Text)
+
+	w.Extract.Format = formatJSON
+	result, err = compareContent(w, snapshot{Content: `{"name":"Widget","price":"10 EUR"}`}, snapshot{Content: `{"name":"WIDGET","price":"12 EUR"}`})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "changed $.price: \"10 EUR\" -> \"12 EUR\"\n", result.Text)
+}
+
+func TestCheckWatchDirection(t *testing.T) {
+	pages := []string{"<p>Price: 10 €</p>\n<p>Stock</p>\n", "<p>Price: 12 €</p>\n<p>Stock</p>\n"}
+	var requests int
+	site := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte(pages[requests]))
+		requests++
+	}))
+	defer site.Close()
+
+	var received []webhookPayload
+	hook := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err, "Expected no error")
+		var payload webhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		received = append(received, payload)
+	}))
+	defer hook.Close()
+
+	db := openTestDB(t)
+	w := defaultWatch()
+	w.Name = "shop"
+	w.URL = site.URL
+	w.Notify = []notifierOptions{{Type: notifierWebhook, URL: hook.URL, Retries: intPointer(0)}}
+
+	// Both crawls usually happen within the same second
+	require.NoError(t, checkWatch(context.Background(), db, w))
+	require.NoError(t, checkWatch(context.Background(), db, w))
+
+	require.Len(t, received, 1)
+	payload := received[0]
+	assert.Contains(t, payload.Diff, "\n-<p>Price: 10 €</p>\n+<p>Price: 12 €</p>\n")
+	assert.LessOrEqual(t, payload.OldCrawlTime, payload.NewCrawlTime)
+	assert.Equal(t, 1, payload.Added)
+	assert.Equal(t, 1, payload.Removed)
+	assert.Equal(t, 1, payload.Changed)
+	assert.Equal(t, []hunk{{OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2, Lines: []string{"-<p>Price: 10 €</p>", "+<p>Price: 12 €</p>", " <p>Stock</p>"}}}, payload.Hunks)
+}
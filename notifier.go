@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const notifierEmail = "email"
+
+// change is the event every notifier of a watch receives when the watched
+// page differs from the previous crawl
+type change struct {
+	Watch    string
+	URL      string
+	FromDate string
+	ToDate   string
+	Text     string
+	HTML     string
+	Stats    changeStats
+	// Hunks are the hunks of the unified diff, empty for JSON
+	Hunks []hunk
+	// Masked counts the regions of the content hidden by ignore rules
+	Masked int
+	// ContentHash is the hex encoded SHA-256 of the current content
+	ContentHash string
+	// Triggers lists the triggers of the watch which fired
+	Triggers []triggerEvent
+	// Value is the extracted value, nil if the watch has none
+	Value *valueChange
+}
+
+// triggered tells whether a trigger fired or a value condition is met
+func (c change) triggered() bool {
+	return len(c.Triggers) > 0 || c.Value != nil && len(c.Value.Conditions) > 0
+}
+
+// changeStats counts the lines of a diff. A modified line counts as
+// removed and added, and as changed.
+type changeStats struct {
+	Added   int
+	Removed int
+	Changed int
+}
+
+// hunk is one hunk of a unified diff. Start lines are 1-based, the lines
+// are prefixed with " ", "-" or "+".
+type hunk struct {
+	OldStart int      `json:"oldStart"`
+	OldLines int      `json:"oldLines"`
+	NewStart int      `json:"newStart"`
+	NewLines int      `json:"newLines"`
+	Lines    []string `json:"lines"`
+}
+
+// notifier delivers a change to one channel
+type notifier interface {
+	Notify(ctx context.Context, c change) error
+}
+
+// notifierOptions configures one entry of a watch's notify list. Which keys
+// apply depends on Type.
+type notifierOptions struct {
+	Type string `yaml:"type"`
+	// To and From override the recipients and sender of the watch for an
+	// email notifier
+	To   []string `yaml:"to"`
+	From string   `yaml:"from"`
+
+	// URL is the endpoint of a webhook or chat notifier
+	URL     string            `yaml:"url"`
+	Secret  string            `yaml:"secret"`
+	Headers map[string]string `yaml:"headers"`
+	Timeout time.Duration     `yaml:"timeout"`
+	// Retries is how often a failed request is repeated, Backoff the delay
+	// before the first repetition which doubles with every further one
+	Retries *int          `yaml:"retries"`
+	Backoff time.Duration `yaml:"backoff"`
+	// MaxDiff limits the diff characters a chat notifier sends
+	MaxDiff int `yaml:"maxDiff"`
+}
+
+// notifiers builds the notifiers of w. Without a notify list, changes are
+// emailed to the recipients of the watch.
+func (w watch) notifiers() ([]notifier, error) {
+	options := w.Notify
+	if len(options) == 0 {
+		options = []notifierOptions{{Type: notifierEmail}}
+	}
+
+	var result []notifier
+	for i, o := range options {
+		var n notifier
+		var err error
+		switch o.Type {
+		case notifierEmail:
+			n, err = newEmailNotifier(w, o)
+		case notifierWebhook:
+			n, err = newWebhookNotifier(o)
+		case notifierSlack, notifierMattermost, notifierTeams:
+			n, err = newChatNotifier(o)
+		default:
+			err = fmt.Errorf("Unknown notifier type: %q", o.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Notifier %d: %s", i+1, err)
+		}
+
+		result = append(result, n)
+	}
+
+	return result, nil
+}
+
+// notifyAll hands c to every notifier, even if some of them fail
+func notifyAll(ctx context.Context, notifiers []notifier, c change) error {
+	var failures []string
+	for _, n := range notifiers {
+		err := n.Notify(ctx, c)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d notifiers failed: %s", len(failures), len(notifiers), strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+type emailNotifier struct {
+	from string
+	to   []string
+	smtp smtpOptions
+}
+
+func newEmailNotifier(w watch, options notifierOptions) (notifier, error) {
+	n := emailNotifier{from: options.From, to: options.To, smtp: w.SMTP}
+	if n.from == "" {
+		n.from = w.From
+	}
+	if len(n.to) == 0 {
+		n.to = w.To
+	}
+
+	if len(n.to) == 0 {
+		return nil, fmt.Errorf("Please specify a Report email")
+	}
+
+	if n.from == "" {
+		return nil, fmt.Errorf("Please specify a Sender email")
+	}
+
+	return n, nil
+}
+
+func (n emailNotifier) Notify(ctx context.Context, c change) error {
+	return sendEmail(emailDifferences(c), n.from, n.to, c.URL, n.smtp)
+}
+
+// changeText is the diff of c preceded by the value and the fired triggers
+func changeText(c change) string {
+	return valueReport(c.Value).text + triggerReport(c.Triggers).text + c.Text
+}
+
+// emailDifferences is the body of the email reporting c, which starts with
+// the value and the fired triggers and mentions the masked regions so it is
+// visible that the ignore rules work
+func emailDifferences(c change) differences {
+	value, triggers := valueReport(c.Value), triggerReport(c.Triggers)
+	diffs := differences{text: value.text + triggers.text + c.Text, html: value.html + triggers.html + c.HTML}
+	if c.Masked > 0 {
+		note := fmt.Sprintf("%d regions were masked by ignore rules.", c.Masked)
+		diffs.text += "\n" + note + "\n"
+		diffs.html += "<p>" + note + "</p>"
+	}
+
+	return diffs
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNotifier remembers every change it receives
+type recordingNotifier struct {
+	changes []change
+	err     error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, c change) error {
+	n.changes = append(n.changes, c)
+	return n.err
+}
+
+func TestWatchNotifiers(t *testing.T) {
+	w := defaultWatch()
+	w.To = []string{"to@test.com"}
+	w.From = "from@test.com"
+
+	notifiers, err := w.notifiers()
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, []notifier{emailNotifier{from: "from@test.com", to: []string{"to@test.com"}, smtp: w.SMTP}}, notifiers)
+
+	w.Notify = []notifierOptions{{Type: notifierEmail}, {Type: notifierEmail, To: []string{"boss@test.com"}, From: "alerts@test.com"}}
+	notifiers, err = w.notifiers()
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, []notifier{
+		emailNotifier{from: "from@test.com", to: []string{"to@test.com"}, smtp: w.SMTP},
+		emailNotifier{from: "alerts@test.com", to: []string{"boss@test.com"}, smtp: w.SMTP},
+	}, notifiers)
+
+	w.Notify = []notifierOptions{{Type: "pager"}}
+	_, err = w.notifiers()
+	assert.Equal(t, "Notifier 1: Unknown notifier type: \"pager\"", err.Error())
+
+	w.Notify = nil
+	w.From = ""
+	_, err = w.notifiers()
+	assert.Equal(t, "Notifier 1: Please specify a Sender email", err.Error())
+}
+
+func TestNotifyAll(t *testing.T) {
+	first := &recordingNotifier{}
+	failing := &recordingNotifier{err: fmt.Errorf("Unable to deliver")}
+	last := &recordingNotifier{}
+
+	c := change{URL: "https://www.test.com", Text: sendEmailDiff.text}
+	err := notifyAll(context.Background(), []notifier{first, failing, last}, c)
+	assert.Equal(t, "1 of 3 notifiers failed: Unable to deliver", err.Error())
+
+	// A failing notifier must not keep the others from being notified
+	assert.Equal(t, []change{c}, first.changes)
+	assert.Equal(t, []change{c}, failing.changes)
+	assert.Equal(t, []change{c}, last.changes)
+
+	err = notifyAll(context.Background(), []notifier{first}, c)
+	require.NoError(t, err, "Expected no error")
+}
+
+func TestEmailNotifier(t *testing.T) {
+	port := startFakeSMTPServer(t, "from@test.com", "to@test.com", "https://www.test.com", fakeSMTPOptions{})
+
+	n := emailNotifier{
+		from: "from@test.com",
+		to:   []string{"to@test.com"},
+		smtp: smtpOptions{Host: "127.0.0.1", Port: port, Encryption: encryptionSTARTTLS, TLSHost: "testdomain.com", CAFile: "./testdata/cert.pem"},
+	}
+	err := n.Notify(context.Background(), change{URL: "https://www.test.com", Text: sendEmailDiff.text, HTML: sendEmailDiff.html})
+	require.NoError(t, err, "Expected no error")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type poolOptions struct {
+	// Concurrency is the number of watches checked at the same time
+	Concurrency int `yaml:"concurrency"`
+	// PerHost is the number of concurrent requests sent to one host
+	PerHost int `yaml:"perHost"`
+	// HostDelay is the minimum time between two requests to one host
+	HostDelay time.Duration `yaml:"hostDelay"`
+}
+
+func defaultPoolOptions() poolOptions {
+	return poolOptions{Concurrency: 4, PerHost: 1, HostDelay: time.Second}
+}
+
+// workerPool limits how many watches are checked at once, overall and per
+// host, so many watches can be checked quickly without hammering one server
+type workerPool struct {
+	options poolOptions
+	slots   chan struct{}
+
+	mutex sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+type hostLimiter struct {
+	slots chan struct{}
+
+	mutex sync.Mutex
+	next  time.Time
+}
+
+func newWorkerPool(options poolOptions) *workerPool {
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+
+	if options.PerHost < 1 {
+		options.PerHost = 1
+	}
+
+	return &workerPool{
+		options: options,
+		slots:   make(chan struct{}, options.Concurrency),
+		hosts:   map[string]*hostLimiter{},
+	}
+}
+
+func hostOf(scanUrl string) string {
+	parsed, err := url.Parse(scanUrl)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(parsed.Hostname())
+}
+
+func (p *workerPool) host(name string) *hostLimiter {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	h, ok := p.hosts[name]
+	if !ok {
+		h = &hostLimiter{slots: make(chan struct{}, p.options.PerHost)}
+		p.hosts[name] = h
+	}
+
+	return h
+}
+
+// reserve returns how long to wait before the next request to the host may be
+// sent, and books that point in time so the following request waits longer
+func (h *hostLimiter) reserve(delay time.Duration) time.Duration {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := time.Now()
+	start := h.next
+	if start.Before(now) {
+		start = now
+	}
+	h.next = start.Add(delay)
+
+	return start.Sub(now)
+}
+
+// run checks w as soon as the limits allow it, or gives up if ctx is
+// cancelled before. The host slot is taken first, so a watch waiting for a
+// busy host does not block watches of other hosts.
+func (p *workerPool) run(ctx context.Context, w watch, check func(watch)) {
+	h := p.host(hostOf(w.URL))
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer func() { <-h.slots }()
+
+	delay := time.NewTimer(h.reserve(p.options.HostDelay))
+	defer delay.Stop()
+	select {
+	case <-delay.C:
+	case <-ctx.Done():
+		return
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer func() { <-p.slots }()
+
+	// select picks at random when ctx was cancelled while a slot got free
+	if ctx.Err() != nil {
+		return
+	}
+
+	check(w)
+}
+
+// limit wraps check so that every call goes through the pool, calls waiting
+// when ctx is cancelled return without checking
+func (p *workerPool) limit(ctx context.Context, check func(watch)) func(watch) {
+	return func(w watch) {
+		p.run(ctx, w, check)
+	}
+}
+
+// runAll checks all watches and returns once every check has finished
+func (p *workerPool) runAll(watches []watch, check func(watch)) {
+	var wg sync.WaitGroup
+	for _, w := range watches {
+		wg.Add(1)
+		go func(w watch) {
+			defer wg.Done()
+			p.run(context.Background(), w, check)
+		}(w)
+	}
+	wg.Wait()
+}
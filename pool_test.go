@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostOf(t *testing.T) {
+	assert.Equal(t, "www.test.com", hostOf("https://WWW.test.com:8443/path"))
+	assert.Equal(t, "", hostOf("http:// test.com"))
+}
+
+func TestWorkerPoolLimits(t *testing.T) {
+	pool := newWorkerPool(poolOptions{Concurrency: 3, PerHost: 1})
+
+	var watches []watch
+	for i := 0; i < 4; i++ {
+		watches = append(watches, watch{URL: fmt.Sprintf("http://a.test.com/%d", i)})
+		watches = append(watches, watch{URL: fmt.Sprintf("http://host%d.test.com/", i)})
+	}
+
+	var mutex sync.Mutex
+	running := 0
+	maxRunning := 0
+	runningHostA := 0
+	maxRunningHostA := 0
+	checked := 0
+	pool.runAll(watches, func(w watch) {
+		isHostA := hostOf(w.URL) == "a.test.com"
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		if isHostA {
+			runningHostA++
+			if runningHostA > maxRunningHostA {
+				maxRunningHostA = runningHostA
+			}
+		}
+		mutex.Unlock()
+
+		time.Sleep(time.Millisecond * 20)
+
+		mutex.Lock()
+		running--
+		if isHostA {
+			runningHostA--
+		}
+		checked++
+		mutex.Unlock()
+	})
+
+	assert.Equal(t, 8, checked)
+	assert.Equal(t, 3, maxRunning)
+	assert.Equal(t, 1, maxRunningHostA)
+}
+
+func TestWorkerPoolHostDelay(t *testing.T) {
+	pool := newWorkerPool(poolOptions{Concurrency: 2, PerHost: 2, HostDelay: time.Millisecond * 50})
+
+	var mutex sync.Mutex
+	var starts []time.Time
+	pool.runAll([]watch{{URL: "http://a.test.com/1"}, {URL: "http://a.test.com/2"}, {URL: "http://a.test.com/3"}}, func(w watch) {
+		mutex.Lock()
+		starts = append(starts, time.Now())
+		mutex.Unlock()
+	})
+
+	require.Len(t, starts, 3)
+	assert.True(t, starts[2].Sub(starts[0]) >= time.Millisecond*100, "Requests to one host were not delayed")
+}
+
+func TestWorkerPoolCancel(t *testing.T) {
+	pool := newWorkerPool(poolOptions{Concurrency: 1, PerHost: 1, HostDelay: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var checked []string
+	check := pool.limit(ctx, func(w watch) {
+		checked = append(checked, w.URL)
+	})
+
+	check(watch{URL: "http://a.test.com/1"})
+
+	// The second request to the host waits for the delay
+	done := make(chan struct{})
+	go func() {
+		check(watch{URL: "http://a.test.com/2"})
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Waiting for the host delay ignored the cancelled context")
+	}
+
+	// Nothing is checked once ctx is cancelled, even if a slot is free
+	check(watch{URL: "http://b.test.com/1"})
+	assert.Equal(t, []string{"http://a.test.com/1"}, checked)
+}
+
+func TestWorkerPoolDefaults(t *testing.T) {
+	pool := newWorkerPool(poolOptions{})
+	assert.Equal(t, 1, pool.options.Concurrency)
+	assert.Equal(t, 1, pool.options.PerHost)
+}
+
+func TestConcurrentDatabaseAccess(t *testing.T) {
+	db := openTestDB(t)
+	pool := newWorkerPool(poolOptions{Concurrency: 8, PerHost: 8})
+
+	var watches []watch
+	for i := 0; i < 32; i++ {
+		watches = append(watches, watch{URL: fmt.Sprintf("http://test.com/%d", i%4)})
+	}
+
+	var mutex sync.Mutex
+	var errors []error
+	pool.runAll(watches, func(w watch) {
+		err := insertRecoredData(db, w.URL, htmlBody, "utf-8", "")
+		if err == nil {
+			_, err = getLastEntries(db, w.URL)
+		}
+		if err != nil {
+			mutex.Lock()
+			errors = append(errors, err)
+			mutex.Unlock()
+		}
+	})
+
+	assert.Empty(t, errors)
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM responseData").Scan(&count)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, 32, count)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// catchUpOnce runs a watch right away when its schedule was missed while
+	// the daemon was down
+	catchUpOnce = "once"
+	// catchUpSkip waits for the next regular run instead
+	catchUpSkip = "skip"
+)
+
+// crawlTimeLayout is the format sqlite's datetime() stores crawl times in
+const crawlTimeLayout = "2006-01-02 15:04:05"
+
+type scheduleOptions struct {
+	Interval time.Duration `yaml:"interval"`
+	Cron     string        `yaml:"cron"`
+	Jitter   time.Duration `yaml:"jitter"`
+	CatchUp  string        `yaml:"catchUp"`
+}
+
+// schedule returns the next time a watch is due after the given time
+type schedule interface {
+	Next(time.Time) time.Time
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+func (options scheduleOptions) schedule() (schedule, error) {
+	if options.CatchUp != "" && options.CatchUp != catchUpOnce && options.CatchUp != catchUpSkip {
+		return nil, fmt.Errorf("Unknown catchUp policy: %s", options.CatchUp)
+	}
+
+	if options.Jitter < 0 {
+		return nil, fmt.Errorf("Jitter must not be negative")
+	}
+
+	switch {
+	case options.Interval != 0 && options.Cron != "":
+		return nil, fmt.Errorf("Please specify either an interval or a cron expression")
+	case options.Interval > 0:
+		return intervalSchedule{interval: options.Interval}, nil
+	case options.Interval < 0:
+		return nil, fmt.Errorf("Interval must be positive")
+	case options.Cron != "":
+		s, err := cron.ParseStandard(options.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cron expression: %s", err)
+		}
+		return s, nil
+	}
+
+	return nil, fmt.Errorf("Please specify an interval or a cron expression")
+}
+
+// firstRun returns when a watch should be checked after the daemon started,
+// based on the last time it was crawled
+func firstRun(s schedule, catchUp string, lastCrawl time.Time, now time.Time) time.Time {
+	if lastCrawl.IsZero() {
+		return now
+	}
+
+	next := s.Next(lastCrawl)
+	if next.After(now) {
+		return next
+	}
+
+	if catchUp == catchUpSkip {
+		return s.Next(now)
+	}
+
+	return now
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+func getLastCrawlTime(db *sql.DB, scanUrl string) (time.Time, error) {
+	var crawlTime sql.NullString
+	err := db.QueryRow("SELECT MAX(crawlTime) FROM responseData WHERE url = ?", scanUrl).Scan(&crawlTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !crawlTime.Valid {
+		return time.Time{}, nil
+	}
+
+	lastCrawl, err := time.ParseInLocation(crawlTimeLayout, crawlTime.String, time.UTC)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Cron expressions are evaluated in the zone of the time they start from
+	return lastCrawl.In(time.Local), nil
+}
+
+// serve checks every watch on its own schedule until ctx is cancelled. Checks
+// which are already running when that happens are finished before it returns.
+func serve(ctx context.Context, db *sql.DB, watches []watch, check func(watch)) error {
+	schedules := make([]schedule, len(watches))
+	for i, w := range watches {
+		s, err := w.Schedule.schedule()
+		if err != nil {
+			return fmt.Errorf("%s: %s", w.Name, err)
+		}
+		schedules[i] = s
+	}
+
+	var wg sync.WaitGroup
+	for i, w := range watches {
+		lastCrawl, err := getLastCrawlTime(db, w.URL)
+		if err != nil {
+			return err
+		}
+
+		next := firstRun(schedules[i], w.Schedule.CatchUp, lastCrawl, time.Now())
+
+		wg.Add(1)
+		go func(w watch, s schedule, next time.Time) {
+			defer wg.Done()
+			runSchedule(ctx, w, s, next, check)
+		}(w, schedules[i], next)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+func runSchedule(ctx context.Context, w watch, s schedule, next time.Time, check func(watch)) {
+	for {
+		timer := time.NewTimer(time.Until(next) + jitter(w.Schedule.Jitter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		log.Println("Checking", w.Name)
+		check(w)
+
+		next = s.Next(time.Now())
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := openDB(filepath.Join(t.TempDir(), "test.sqlite"))
+	require.NoError(t, err, "Expected no error")
+	t.Cleanup(func() { db.Close() })
+
+	err = initializeDB(db)
+	require.NoError(t, err, "Expected no error")
+
+	return db
+}
+
+func TestScheduleOptions(t *testing.T) {
+	s, err := scheduleOptions{Interval: time.Minute}.schedule()
+	require.NoError(t, err, "Expected no error")
+	start := time.Date(2021, 3, 1, 10, 0, 30, 0, time.UTC)
+	assert.Equal(t, start.Add(time.Minute), s.Next(start))
+
+	s, err = scheduleOptions{Cron: "*/15 * * * *"}.schedule()
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, time.Date(2021, 3, 1, 10, 15, 0, 0, time.UTC), s.Next(start))
+
+	_, err = scheduleOptions{}.schedule()
+	assert.Equal(t, "Please specify an interval or a cron expression", err.Error())
+
+	_, err = scheduleOptions{Interval: time.Minute, Cron: "@hourly"}.schedule()
+	assert.Equal(t, "Please specify either an interval or a cron expression", err.Error())
+
+	_, err = scheduleOptions{Interval: -time.Minute}.schedule()
+	assert.Equal(t, "Interval must be positive", err.Error())
+
+	_, err = scheduleOptions{Cron: "every minute"}.schedule()
+	assert.Contains(t, err.Error(), "Invalid cron expression")
+
+	_, err = scheduleOptions{Interval: time.Minute, CatchUp: "all"}.schedule()
+	assert.Equal(t, "Unknown catchUp policy: all", err.Error())
+
+	_, err = scheduleOptions{Interval: time.Minute, Jitter: -time.Second}.schedule()
+	assert.Equal(t, "Jitter must not be negative", err.Error())
+}
+
+func TestFirstRun(t *testing.T) {
+	s := intervalSchedule{interval: time.Hour}
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	// Never crawled before
+	assert.Equal(t, now, firstRun(s, catchUpSkip, time.Time{}, now))
+
+	// Not due yet
+	assert.Equal(t, now.Add(time.Minute*30), firstRun(s, catchUpOnce, now.Add(-time.Minute*30), now))
+
+	// Missed while the daemon was down
+	assert.Equal(t, now, firstRun(s, catchUpOnce, now.Add(-time.Hour*5), now))
+	assert.Equal(t, now, firstRun(s, "", now.Add(-time.Hour*5), now))
+	assert.Equal(t, now.Add(time.Hour), firstRun(s, catchUpSkip, now.Add(-time.Hour*5), now))
+}
+
+func TestJitter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), jitter(0))
+	for i := 0; i < 100; i++ {
+		j := jitter(time.Second)
+		assert.True(t, j >= 0 && j < time.Second, "Jitter out of range: %s", j)
+	}
+}
+
+func TestGetLastCrawlTime(t *testing.T) {
+	db := openTestDB(t)
+
+	lastCrawl, err := getLastCrawlTime(db, "http://www.test.com")
+	require.NoError(t, err, "Expected no error")
+	assert.True(t, lastCrawl.IsZero())
+
+	_, err = db.Exec("INSERT INTO responseData(url, crawlTime, response) values(?, ?, ?)", "http://www.test.com", "2021-03-01 10:00:00", "")
+	require.NoError(t, err, "Expected no error")
+	_, err = db.Exec("INSERT INTO responseData(url, crawlTime, response) values(?, ?, ?)", "http://www.test.com", "2021-03-01 11:00:00", "")
+	require.NoError(t, err, "Expected no error")
+
+	lastCrawl, err = getLastCrawlTime(db, "http://www.test.com")
+	require.NoError(t, err, "Expected no error")
+	assert.True(t, time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC).Equal(lastCrawl))
+	assert.Equal(t, time.Local, lastCrawl.Location())
+}
+
+func TestFirstRunLocalCron(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	db := openTestDB(t)
+	// Crawled at 08:30 local time
+	_, err := db.Exec("INSERT INTO responseData(url, crawlTime, response) values(?, ?, ?)", "http://www.test.com", "2021-03-01 06:30:00", "")
+	require.NoError(t, err, "Expected no error")
+
+	lastCrawl, err := getLastCrawlTime(db, "http://www.test.com")
+	require.NoError(t, err, "Expected no error")
+
+	s, err := scheduleOptions{Cron: "0 9 * * *"}.schedule()
+	require.NoError(t, err, "Expected no error")
+	now := time.Date(2021, 3, 1, 6, 45, 0, 0, time.UTC)
+	next := firstRun(s, catchUpSkip, lastCrawl, now)
+	assert.True(t, time.Date(2021, 3, 1, 7, 0, 0, 0, time.UTC).Equal(next), "Expected 09:00 local time, got %s", next)
+}
+
+func TestServe(t *testing.T) {
+	db := openTestDB(t)
+	watches := []watch{
+		{Name: "fast", URL: "http://fast.test.com", Schedule: scheduleOptions{Interval: time.Millisecond * 10}},
+		{Name: "slow", URL: "http://slow.test.com", Schedule: scheduleOptions{Interval: time.Hour}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var mutex sync.Mutex
+	checks := map[string]int{}
+	finished := false
+	check := func(w watch) {
+		mutex.Lock()
+		checks[w.Name]++
+		count := checks[w.Name]
+		mutex.Unlock()
+
+		if w.Name == "fast" && count == 3 {
+			cancel()
+			// A check which is running during shutdown has to finish
+			time.Sleep(time.Millisecond * 50)
+			mutex.Lock()
+			finished = true
+			mutex.Unlock()
+		}
+	}
+
+	err := serve(ctx, db, watches, check)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, 3, checks["fast"])
+	assert.Equal(t, 1, checks["slow"])
+	assert.True(t, finished)
+}
+
+func TestServeInvalidSchedule(t *testing.T) {
+	db := openTestDB(t)
+	err := serve(context.Background(), db, []watch{{Name: "broken"}}, func(watch) {})
+	assert.Equal(t, "broken: Please specify an interval or a cron expression", err.Error())
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	encryptionSTARTTLS = "starttls"
+	encryptionTLS      = "tls"
+	encryptionNone     = "none"
+
+	authPlain   = "plain"
+	authLogin   = "login"
+	authCRAMMD5 = "cram-md5"
+)
+
+type smtpOptions struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Auth is one of plain, login or cram-md5 and only used with a username
+	Auth string `yaml:"auth"`
+	// Encryption is one of starttls, tls (implicit TLS, usually on port 465)
+	// or none
+	Encryption string `yaml:"encryption"`
+	// TLSHost is the name the server certificate has to match, defaults to Host
+	TLSHost            string `yaml:"tlsHost"`
+	CAFile             string `yaml:"caFile"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+	// Fingerprint pins the server certificate to its hex encoded SHA-256 hash.
+	// A pinned certificate is accepted without checking its chain, which
+	// allows self-signed certificates.
+	Fingerprint string `yaml:"fingerprint"`
+}
+
+func defaultSMTPOptions() smtpOptions {
+	return smtpOptions{Host: "localhost", Port: 587, Encryption: encryptionSTARTTLS}
+}
+
+func (options smtpOptions) validate() error {
+	switch strings.ToLower(options.Encryption) {
+	case encryptionSTARTTLS, encryptionTLS, encryptionNone:
+	default:
+		return fmt.Errorf("Unknown SMTP encryption: %s", options.Encryption)
+	}
+
+	switch strings.ToLower(options.Auth) {
+	case "", authPlain, authLogin, authCRAMMD5:
+	default:
+		return fmt.Errorf("Unknown SMTP authentication: %s", options.Auth)
+	}
+
+	if options.Host == "" {
+		return fmt.Errorf("Please specify the SMTP host")
+	}
+
+	return nil
+}
+
+func (options smtpOptions) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         options.TLSHost,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+	if config.ServerName == "" {
+		config.ServerName = options.Host
+	}
+
+	if options.CAFile != "" {
+		pem, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA file: %s", err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in CA file: %s", options.CAFile)
+		}
+	}
+
+	if options.Fingerprint != "" {
+		expected := strings.ToLower(strings.Replace(options.Fingerprint, ":", "", -1))
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("No server certificate received")
+			}
+
+			sum := sha256.Sum256(rawCerts[0])
+			if hex.EncodeToString(sum[:]) != expected {
+				return fmt.Errorf("Server certificate fingerprint does not match")
+			}
+
+			return nil
+		}
+	}
+
+	return config, nil
+}
+
+func (options smtpOptions) auth() smtp.Auth {
+	switch strings.ToLower(options.Auth) {
+	case authLogin:
+		return &loginAuth{username: options.Username, password: options.Password, host: options.Host}
+	case authCRAMMD5:
+		return smtp.CRAMMD5Auth(options.Username, options.Password)
+	}
+
+	return smtp.PlainAuth("", options.Username, options.Password, options.Host)
+}
+
+// dialSMTP connects to the configured server, sets up encryption and logs in
+func dialSMTP(options smtpOptions) (*smtp.Client, error) {
+	tlsConfig, err := options.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	address := net.JoinHostPort(options.Host, strconv.Itoa(options.Port))
+	dialer := &net.Dialer{Timeout: time.Second * 10}
+
+	encryption := strings.ToLower(options.Encryption)
+	var conn net.Conn
+	if encryption == encryptionTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, options.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if encryption == encryptionSTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("Server does not support STARTTLS")
+		}
+
+		err = client.StartTLS(tlsConfig)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	if options.Username != "" {
+		err = client.Auth(options.auth())
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// smtpSender implements gomail.SendCloser on top of a net/smtp client
+type smtpSender struct {
+	client *smtp.Client
+}
+
+func (s *smtpSender) Send(from string, to []string, msg io.WriterTo) error {
+	err := s.client.Mail(from)
+	if err != nil {
+		return err
+	}
+
+	for _, address := range to {
+		err = s.client.Rcpt(address)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer, err := s.client.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = msg.WriteTo(writer)
+	if err != nil {
+		writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
+
+func (s *smtpSender) Close() error {
+	return s.client.Quit()
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp does not offer
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Same rule as smtp.PlainAuth: never send the password unencrypted to
+	// anything but localhost
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return "", nil, fmt.Errorf("unencrypted connection")
+	}
+
+	if server.Name != a.host {
+		return "", nil, fmt.Errorf("wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+
+	return nil, fmt.Errorf("Unexpected LOGIN challenge: %s", fromServer)
+}
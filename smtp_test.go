@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/smtp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPOptionsValidate(t *testing.T) {
+	assert.NoError(t, defaultSMTPOptions().validate())
+
+	options := defaultSMTPOptions()
+	options.Encryption = "STARTTLS"
+	assert.NoError(t, options.validate())
+	options.Encryption = "TLS"
+	assert.NoError(t, options.validate())
+
+	options.Encryption = "ssl"
+	assert.Equal(t, "Unknown SMTP encryption: ssl", options.validate().Error())
+
+	options = defaultSMTPOptions()
+	options.Auth = "ntlm"
+	assert.Equal(t, "Unknown SMTP authentication: ntlm", options.validate().Error())
+
+	options = defaultSMTPOptions()
+	options.Host = ""
+	assert.Equal(t, "Please specify the SMTP host", options.validate().Error())
+}
+
+func TestSMTPOptionsTLSConfig(t *testing.T) {
+	config, err := smtpOptions{Host: "mail.test.com"}.tlsConfig()
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "mail.test.com", config.ServerName)
+	assert.Nil(t, config.RootCAs)
+
+	config, err = smtpOptions{Host: "mail.test.com", TLSHost: "testdomain.com", CAFile: "testdata/cert.pem"}.tlsConfig()
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "testdomain.com", config.ServerName)
+	assert.NotNil(t, config.RootCAs)
+
+	_, err = smtpOptions{CAFile: "testdata/missing.pem"}.tlsConfig()
+	assert.Contains(t, err.Error(), "Unable to read CA file")
+
+	_, err = smtpOptions{CAFile: "README.md"}.tlsConfig()
+	assert.Equal(t, "No certificates found in CA file: README.md", err.Error())
+}
+
+func TestLoginAuth(t *testing.T) {
+	auth := &loginAuth{username: "user", password: "secret", host: "mail.test.com"}
+
+	_, _, err := auth.Start(&smtp.ServerInfo{Name: "mail.test.com"})
+	assert.Equal(t, "unencrypted connection", err.Error())
+
+	_, _, err = auth.Start(&smtp.ServerInfo{Name: "other.test.com", TLS: true})
+	assert.Equal(t, "wrong host name", err.Error())
+
+	mechanism, _, err := auth.Start(&smtp.ServerInfo{Name: "mail.test.com", TLS: true})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "LOGIN", mechanism)
+
+	response, err := auth.Next([]byte("Username:"), true)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "user", string(response))
+
+	response, err = auth.Next([]byte("Password:"), true)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "secret", string(response))
+
+	_, err = auth.Next([]byte("Token:"), true)
+	assert.Equal(t, "Unexpected LOGIN challenge: Token:", err.Error())
+
+	response, err = auth.Next(nil, false)
+	require.NoError(t, err, "Expected no error")
+	assert.Nil(t, response)
+}
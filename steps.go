@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// variableName is the name of a variable extracted by a step
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// variables hold the values extracted by the steps of a watch
+type variables map[string]string
+
+// stepOptions describe one request of a sequence like a login form. The
+// steps share their cookies, and the body of the last step is compared.
+type stepOptions struct {
+	// URL defaults to the URL of the watch
+	URL string `yaml:"url"`
+	// Method, Headers and the body work like those of fetch, the headers of
+	// fetch are sent as well
+	Method  string            `yaml:"method"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+	Form    map[string]string `yaml:"form"`
+	JSON    interface{}       `yaml:"json"`
+	// Extract stores values of the response as variables for the following
+	// steps, which use them as ${NAME}
+	Extract map[string]variableOptions `yaml:"extract"`
+}
+
+// variableOptions find a value in the response of a step
+type variableOptions struct {
+	// Selector is a CSS selector, the text of the first matching element or
+	// its Attribute is used
+	Selector  string `yaml:"selector"`
+	Attribute string `yaml:"attribute"`
+	// Pattern is a regular expression searching the body, or the selected
+	// text, its first group if it has one
+	Pattern string `yaml:"pattern"`
+	// Header takes the value of a response header
+	Header string `yaml:"header"`
+}
+
+func (options variableOptions) validate() error {
+	if options.Selector == "" && options.Pattern == "" && options.Header == "" {
+		return fmt.Errorf("Please specify a selector, pattern or header")
+	}
+
+	if options.Header != "" && options.Selector != "" {
+		return fmt.Errorf("Please specify either a selector or a header")
+	}
+
+	if options.Selector != "" {
+		_, err := cascadia.Compile(options.Selector)
+		if err != nil {
+			return fmt.Errorf("Invalid selector %q: %s", options.Selector, err)
+		}
+	}
+
+	if options.Pattern != "" {
+		_, err := regexp.Compile(options.Pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid pattern %q: %s", options.Pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// fetchOptions returns the options of the watch with the request of the step
+func (s stepOptions) fetchOptions(options fetchOptions) fetchOptions {
+	options.Method = s.Method
+	options.Body = s.Body
+	options.Form = s.Form
+	options.JSON = s.JSON
+
+	headers := copyStringMap(options.Headers)
+	for name, value := range s.Headers {
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers[name] = value
+	}
+	options.Headers = headers
+	options.Steps = nil
+
+	return options
+}
+
+func (s stepOptions) validate(options fetchOptions) error {
+	err := s.fetchOptions(options).validate()
+	if err != nil {
+		return err
+	}
+
+	for name, variable := range s.Extract {
+		if !variableName.MatchString(name) {
+			return fmt.Errorf("Invalid variable name %q", name)
+		}
+
+		err = variable.validate()
+		if err != nil {
+			return fmt.Errorf("Variable %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// extractVariable finds the value of a variable in a response
+func extractVariable(options variableOptions, header http.Header, body string) (string, error) {
+	if options.Header != "" {
+		value := header.Get(options.Header)
+		if value == "" {
+			return "", fmt.Errorf("Header %s is missing", options.Header)
+		}
+		body = value
+	}
+
+	if options.Selector != "" {
+		document, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+		if err != nil {
+			return "", fmt.Errorf("Unable to parse HTML: %s", err)
+		}
+
+		selection := document.Find(options.Selector).First()
+		if selection.Length() == 0 {
+			return "", fmt.Errorf("Selector %q matches nothing", options.Selector)
+		}
+
+		if options.Attribute != "" {
+			value, found := selection.Attr(options.Attribute)
+			if !found {
+				return "", fmt.Errorf("Attribute %s is missing", options.Attribute)
+			}
+			body = value
+		} else {
+			body = strings.TrimSpace(selection.Text())
+		}
+	}
+
+	if options.Pattern == "" {
+		return body, nil
+	}
+
+	match := regexp.MustCompile(options.Pattern).FindStringSubmatch(body)
+	if match == nil {
+		return "", fmt.Errorf("Pattern %q matches nothing", options.Pattern)
+	}
+	if len(match) > 1 {
+		return match[1], nil
+	}
+
+	return match[0], nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractVariable(t *testing.T) {
+	body := `<form><input type="hidden" name="csrf" value="abc123"><p class="hint">Token: xyz</p></form>`
+	header := http.Header{"Location": {"/account?id=42"}}
+
+	tests := []struct {
+		options  variableOptions
+		expected string
+	}{
+		{variableOptions{Selector: "input[name=csrf]", Attribute: "value"}, "abc123"},
+		{variableOptions{Selector: ".hint"}, "Token: xyz"},
+		{variableOptions{Selector: ".hint", Pattern: `Token: (\w+)`}, "xyz"},
+		{variableOptions{Pattern: `name="csrf" value="[^"]+"`}, `name="csrf" value="abc123"`},
+		{variableOptions{Header: "Location", Pattern: `id=(\d+)`}, "42"},
+	}
+	for _, test := range tests {
+		value, err := extractVariable(test.options, header, body)
+		require.NoError(t, err, "Expected no error")
+		assert.Equal(t, test.expected, value)
+	}
+
+	_, err := extractVariable(variableOptions{Selector: "#missing"}, header, body)
+	assert.EqualError(t, err, "Selector \"#missing\" matches nothing")
+	_, err = extractVariable(variableOptions{Selector: "input", Attribute: "data-id"}, header, body)
+	assert.EqualError(t, err, "Attribute data-id is missing")
+	_, err = extractVariable(variableOptions{Pattern: `nonce=(\w+)`}, header, body)
+	assert.EqualError(t, err, "Pattern \"nonce=(\\\\w+)\" matches nothing")
+	_, err = extractVariable(variableOptions{Header: "X-Token"}, header, body)
+	assert.EqualError(t, err, "Header X-Token is missing")
+}
+
+func TestStepValidate(t *testing.T) {
+	options := fetchOptions{Steps: []stepOptions{{URL: "https://www.test.com/login", Extract: map[string]variableOptions{"csrf": {Selector: "input", Attribute: "value"}}}, {Form: map[string]string{"csrf": "${csrf}"}}}}
+	assert.NoError(t, options.validate())
+
+	options.Steps[1].Body = "a"
+	assert.EqualError(t, options.validate(), "Step 2: Please specify only one of body, form and json")
+
+	options.Steps = []stepOptions{{Extract: map[string]variableOptions{"csrf-token": {Pattern: "a"}}}}
+	assert.EqualError(t, options.validate(), "Step 1: Invalid variable name \"csrf-token\"")
+
+	options.Steps = []stepOptions{{Extract: map[string]variableOptions{"csrf": {}}}}
+	assert.EqualError(t, options.validate(), "Step 1: Variable csrf: Please specify a selector, pattern or header")
+
+	options.Steps = []stepOptions{{Extract: map[string]variableOptions{"csrf": {Selector: "<"}}}}
+	assert.Contains(t, options.validate().Error(), "Step 1: Variable csrf: Invalid selector")
+}
+
+// newLoginServer serves a login form protected by a CSRF token and a
+// session cookie, /account is only shown after logging in
+func newLoginServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(res http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet {
+			http.SetCookie(res, &http.Cookie{Name: "form", Value: "f1", Path: "/"})
+			res.Write([]byte(`<form method="post"><input type="hidden" name="csrf" value="token-1"></form>`))
+			return
+		}
+
+		form, err := req.Cookie("form")
+		if err != nil || form.Value != "f1" || req.FormValue("csrf") != "token-1" {
+			res.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if req.FormValue("user") != "me" || req.FormValue("password") != "secret" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(res, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		http.Redirect(res, req, "/welcome", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/welcome", func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("X-Account", "7")
+		res.Write([]byte("<p>Welcome</p>"))
+	})
+	mux.HandleFunc("/account", func(res http.ResponseWriter, req *http.Request) {
+		session, err := req.Cookie("session")
+		if err != nil || session.Value != "s1" || req.URL.Query().Get("id") != "7" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		res.Write([]byte("<p>Balance: 10 €</p>"))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func loginSteps(server *httptest.Server) []stepOptions {
+	return []stepOptions{
+		{URL: server.URL + "/login", Extract: map[string]variableOptions{"csrf": {Selector: "input[name=csrf]", Attribute: "value"}}},
+		{
+			URL:     server.URL + "/login",
+			Form:    map[string]string{"user": "me", "password": "${WD_TEST_PASSWORD}", "csrf": "${csrf}"},
+			Extract: map[string]variableOptions{"account": {Header: "X-Account"}},
+		},
+		{URL: server.URL + "/account?id=${account}"},
+	}
+}
+
+func TestGetContentSteps(t *testing.T) {
+	server := newLoginServer(t)
+	setenv(t, "WD_TEST_PASSWORD", "secret")
+
+	response, _, err := getContent(server.URL+"/account", fetchOptions{Steps: loginSteps(server)}, nil)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "<p>Balance: 10 €</p>", string(response))
+
+	// Without the login the page is not accessible
+	_, _, err = getContent(server.URL+"/account", fetchOptions{}, nil)
+	assert.EqualError(t, err, "Incorrect HTTP Status Code: 401 Unauthorized")
+
+	setenv(t, "WD_TEST_PASSWORD", "wrong")
+	_, _, err = getContent(server.URL+"/account", fetchOptions{Steps: loginSteps(server)}, nil)
+	assert.EqualError(t, err, "Step 2: Incorrect HTTP Status Code: 401 Unauthorized")
+
+	steps := loginSteps(server)
+	steps[0].Extract["csrf"] = variableOptions{Selector: "input[name=token]"}
+	_, _, err = getContent(server.URL+"/account", fetchOptions{Steps: steps}, nil)
+	assert.EqualError(t, err, "Step 1: Variable csrf: Selector \"input[name=token]\" matches nothing")
+}
+
+func TestCheckWatchSteps(t *testing.T) {
+	server := newLoginServer(t)
+	setenv(t, "WD_TEST_PASSWORD", "secret")
+
+	db := openTestDB(t)
+	w := defaultWatch()
+	w.URL = server.URL + "/account"
+	w.Fetch.Steps = loginSteps(server)
+	w.Notify = []notifierOptions{{Type: notifierWebhook, URL: server.URL}}
+	require.NoError(t, checkWatch(context.Background(), db, w))
+
+	entries, err := getLastEntries(db, w.URL)
+	require.NoError(t, err, "Expected no error")
+	require.Len(t, entries, 1)
+	assert.Equal(t, "<p>Balance: 10 €</p>", entries[0].response)
+}
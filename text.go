@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// blockElements start and end a line of text
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Dd: true, atom.Details: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Fieldset: true, atom.Figcaption: true, atom.Figure: true, atom.Footer: true,
+	atom.Form: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true, atom.Li: true,
+	atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true,
+	atom.Section: true, atom.Summary: true, atom.Table: true, atom.Tr: true, atom.Ul: true,
+}
+
+// hiddenElements never contribute text
+var hiddenElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Iframe: true, atom.Object: true, atom.Svg: true,
+}
+
+// textRenderer collects the lines of text of an HTML document
+type textRenderer struct {
+	lines []string
+	line  strings.Builder
+	// prefix is written before the first text of the next line, it holds
+	// the bullet of a list item
+	prefix string
+	// space is set when whitespace was seen since the last written text
+	space bool
+	lists []*int
+}
+
+// htmlToText renders HTML as readable text: block elements become lines,
+// list items get bullets, links are written as "text (url)" and scripts and
+// styles are dropped
+func htmlToText(markup string) (string, error) {
+	document, err := html.Parse(strings.NewReader(markup))
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse HTML: %s", err)
+	}
+
+	r := &textRenderer{}
+	r.render(document)
+	r.breakLine()
+
+	if len(r.lines) == 0 {
+		return "", nil
+	}
+
+	return strings.Join(r.lines, "\n") + "\n", nil
+}
+
+func (r *textRenderer) breakLine() {
+	if r.line.Len() > 0 {
+		r.lines = append(r.lines, r.line.String())
+		r.line.Reset()
+	}
+	r.space = false
+}
+
+// write adds inline text, collapsing whitespace like a browser does
+func (r *textRenderer) write(text string) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		r.space = r.space || text != ""
+		return
+	}
+
+	r.space = r.space || isSpace(text[0])
+	for i, word := range words {
+		if r.line.Len() == 0 {
+			r.line.WriteString(r.prefix)
+			r.prefix = ""
+		} else if r.space || i > 0 {
+			r.line.WriteString(" ")
+		}
+		r.line.WriteString(word)
+		r.space = false
+	}
+	r.space = isSpace(text[len(text)-1])
+}
+
+func isSpace(c byte) bool {
+	return strings.IndexByte(" \t\n\r\f", c) >= 0
+}
+
+// writePreformatted keeps the line breaks and spacing of a pre element
+func (r *textRenderer) writePreformatted(text string) {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		if i > 0 {
+			r.breakLine()
+		}
+		if strings.TrimSpace(line) != "" {
+			r.line.WriteString(r.prefix + strings.TrimRight(line, " \t\r"))
+			r.prefix = ""
+		}
+	}
+}
+
+func (r *textRenderer) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.write(n.Data)
+		return
+	case html.ElementNode:
+	case html.DocumentNode:
+		r.renderChildren(n)
+		return
+	default:
+		return
+	}
+
+	if hiddenElements[n.DataAtom] {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		r.breakLine()
+		return
+	case atom.Pre:
+		r.breakLine()
+		r.writePreformatted(nodeText(n))
+		r.breakLine()
+		return
+	case atom.Ul, atom.Ol:
+		counter := 0
+		if n.DataAtom == atom.Ul {
+			r.lists = append(r.lists, nil)
+		} else {
+			r.lists = append(r.lists, &counter)
+		}
+		r.breakLine()
+		r.renderChildren(n)
+		r.breakLine()
+		r.lists = r.lists[:len(r.lists)-1]
+		return
+	case atom.Li:
+		r.breakLine()
+		r.prefix = r.bullet()
+		r.renderChildren(n)
+		r.breakLine()
+		r.prefix = ""
+		return
+	case atom.Td, atom.Th:
+		if previousElement(n) != nil {
+			r.write(" | ")
+		}
+		r.renderChildren(n)
+		return
+	case atom.A:
+		r.renderChildren(n)
+		r.writeLink(n)
+		return
+	}
+
+	if blockElements[n.DataAtom] {
+		r.breakLine()
+		r.renderChildren(n)
+		r.breakLine()
+		return
+	}
+
+	r.renderChildren(n)
+}
+
+func (r *textRenderer) renderChildren(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		r.render(child)
+	}
+}
+
+// bullet returns the marker of a list item, indented by the nesting depth
+func (r *textRenderer) bullet() string {
+	if len(r.lists) == 0 {
+		return "- "
+	}
+
+	indent := strings.Repeat("  ", len(r.lists)-1)
+	counter := r.lists[len(r.lists)-1]
+	if counter == nil {
+		return indent + "- "
+	}
+
+	*counter++
+
+	return indent + strconv.Itoa(*counter) + ". "
+}
+
+// writeLink appends the target of a link unless it is the link text or does
+// not lead anywhere
+func (r *textRenderer) writeLink(n *html.Node) {
+	href := strings.TrimSpace(attribute(n, "href"))
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return
+	}
+
+	text := strings.Join(strings.Fields(nodeText(n)), " ")
+	if text == href {
+		return
+	}
+
+	if text == "" {
+		r.write(href)
+		return
+	}
+
+	r.write(" (" + href + ")")
+}
+
+func attribute(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && hiddenElements[child.DataAtom] {
+			continue
+		}
+		b.WriteString(nodeText(child))
+	}
+
+	return b.String()
+}
+
+func previousElement(n *html.Node) *html.Node {
+	for sibling := n.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+		if sibling.Type == html.ElementNode {
+			return sibling
+		}
+	}
+
+	return nil
+}
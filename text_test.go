@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTMLToText(t *testing.T) {
+	text, err := htmlToText(`<html><head><title>Shop</title><style>p { color: red }</style></head>
+<body>
+<script>var tracking = 1;</script>
+<h1>Widget   <small>v2</small></h1>
+<p>Only <b>10 €</b>, see
+  <a href="/terms">the terms</a> or <a href="https://example.com">https://example.com</a>.<br>Ships today.</p>
+<ul>
+  <li>Red</li>
+  <li>Blue<ol><li>Light</li><li>Dark</li></ol></li>
+</ul>
+<table><tr><th>Weight</th><td>1 kg</td></tr></table>
+<pre>  a  b
+c</pre>
+<a href="#top"><img src="up.png"></a><a href="/cart"><img src="cart.png"></a>
+</body></html>`)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, `Widget v2
+Only 10 €, see the terms (/terms) or https://example.com.
+Ships today.
+- Red
+- Blue
+  1. Light
+  2. Dark
+Weight | 1 kg
+  a  b
+c
+/cart
+`, text)
+}
+
+func TestHTMLToTextIgnoresMarkup(t *testing.T) {
+	before, err := htmlToText(`<div class="a" id="b"><span>Price:</span> <b>10</b></div>`)
+	require.NoError(t, err, "Expected no error")
+	after, err := htmlToText(`<div id="b" class="a">
+	<span>Price:</span><i> 10</i>
+</div>`)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "Price: 10\n", before)
+	assert.Equal(t, before, after)
+
+	text, err := htmlToText("<script>only()</script>")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "", text)
+}
+
+func TestProcessContentText(t *testing.T) {
+	w := defaultWatch()
+	w.Extract.Selector = "#product"
+	w.Extract.Exclude = []string{"form"}
+	w.Extract.Text = true
+
+	content, err := processContent(w, htmlShop)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "Widget\n10 €\n", content)
+}
+
+func TestExtractOptionsValidateText(t *testing.T) {
+	assert.NoError(t, extractOptions{Text: true, XPath: "//div"}.validate())
+
+	err := extractOptions{Text: true, Format: formatJSON}.validate()
+	assert.EqualError(t, err, "Text mode needs HTML content")
+
+	err = extractOptions{Text: true, XPath: "//div", XPathResult: xpathResultText}.validate()
+	assert.EqualError(t, err, "Text mode needs HTML content")
+}
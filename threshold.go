@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"WD/difflib"
+)
+
+// charDiffLimit is the size in characters above which replaced blocks are no
+// longer compared character by character, as that is quadratic
+const charDiffLimit = 4096
+
+// thresholdOptions decide which changes are significant enough to notify
+// about. A change has to reach every threshold which is set, the others
+// are recorded in the history only.
+type thresholdOptions struct {
+	// MinLines is the number of lines which must have changed, a modified
+	// line counts once
+	MinLines int `yaml:"minLines"`
+	// MinChars is the number of characters which must have changed
+	MinChars int `yaml:"minChars"`
+	// MaxRatio is the similarity of the old and the new content, as
+	// SequenceMatcher.Ratio() computes it, which must not be exceeded. 0
+	// disables it.
+	MaxRatio float64 `yaml:"maxRatio"`
+}
+
+func (options thresholdOptions) validate() error {
+	if options.MinLines < 0 {
+		return fmt.Errorf("Threshold minLines must not be negative")
+	}
+
+	if options.MinChars < 0 {
+		return fmt.Errorf("Threshold minChars must not be negative")
+	}
+
+	if options.MaxRatio < 0 || options.MaxRatio > 1 {
+		return fmt.Errorf("Threshold maxRatio must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// changeSize describes how much the content changed between two crawls
+type changeSize struct {
+	Lines int
+	Chars int
+	Ratio float64
+}
+
+func (options thresholdOptions) reached(size changeSize) bool {
+	if size.Lines < options.MinLines || size.Chars < options.MinChars {
+		return false
+	}
+
+	return options.MaxRatio == 0 || size.Ratio <= options.MaxRatio
+}
+
+// measureChange compares the lines of both contents like getDifferences
+// does and counts the changed lines and characters
+func measureChange(oldContent string, newContent string, options diffOptions) changeSize {
+	a := difflib.SplitLines(oldContent)
+	b := difflib.SplitLines(newContent)
+	algorithm := options.algorithm()
+	if algorithm == nil {
+		algorithm = difflib.RatcliffObershelp
+	}
+
+	var size changeSize
+	for _, c := range algorithm.OpCodes(a, b) {
+		if c.Tag == 'e' {
+			continue
+		}
+
+		lines := c.I2 - c.I1
+		if c.J2-c.J1 > lines {
+			lines = c.J2 - c.J1
+		}
+		size.Lines += lines
+		size.Chars += changedChars(strings.Join(a[c.I1:c.I2], ""), strings.Join(b[c.J1:c.J2], ""))
+	}
+
+	// The ratio does not depend on the algorithm of the diff
+	if options.ignoreCase {
+		a, b = lowerLines(a), lowerLines(b)
+	}
+	size.Ratio = difflib.NewMatcher(a, b).Ratio()
+
+	return size
+}
+
+// changedChars counts the characters removed from or added to a block,
+// whichever is more. Large blocks count as completely replaced.
+func changedChars(oldBlock string, newBlock string) int {
+	oldLength, newLength := len([]rune(oldBlock)), len([]rune(newBlock))
+	removed, added := 0, 0
+	if oldLength == 0 || newLength == 0 || oldLength > charDiffLimit || newLength > charDiffLimit {
+		removed, added = oldLength, newLength
+	} else {
+		for _, span := range difflib.CharDiff(oldBlock, newBlock) {
+			switch span.Tag {
+			case 'd':
+				removed += len([]rune(span.Text))
+			case 'i':
+				added += len([]rune(span.Text))
+			}
+		}
+	}
+
+	if removed > added {
+		return removed
+	}
+
+	return added
+}
+
+// recordChange stores a change in the history and notifies about it if it
+// reaches the thresholds of the watch, one of its triggers fired or a value
+// condition is met
+func recordChange(ctx context.Context, db *sql.DB, notifiers []notifier, w watch, oldContent string, newContent string, c change) error {
+	size := measureChange(oldContent, newContent, w.diffOptions())
+	significant := c.triggered() || !w.TriggersOnly && w.Threshold.reached(size)
+
+	_, err := db.Exec("INSERT INTO changeLog(url, fromDate, toDate, lines, chars, ratio, significant, diff) values(?, ?, ?, ?, ?, ?, ?, ?)",
+		c.URL, c.FromDate, c.ToDate, size.Lines, size.Chars, size.Ratio, significant, changeText(c))
+	if err != nil {
+		return err
+	}
+
+	if !significant {
+		log.Printf("%s: Not notifying, %d lines and %d characters changed", w.Name, size.Lines, size.Chars)
+		return nil
+	}
+
+	return notifyAll(ctx, notifiers, c)
+}
+
+// historyEntry is one recorded change of a watched URL
+type historyEntry struct {
+	URL         string
+	FromDate    string
+	ToDate      string
+	Size        changeSize
+	Significant bool
+	Diff        string
+}
+
+// getHistory returns the last limit changes, of every URL if scanUrl is
+// empty, newest first
+func getHistory(db *sql.DB, scanUrl string, limit int) ([]historyEntry, error) {
+	rows, err := db.Query("SELECT url, fromDate, toDate, lines, chars, ratio, significant, diff FROM changeLog WHERE ? = '' OR url = ? ORDER BY toDate DESC, rowid DESC LIMIT ?", scanUrl, scanUrl, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []historyEntry
+	for rows.Next() {
+		var entry historyEntry
+		err = rows.Scan(&entry.URL, &entry.FromDate, &entry.ToDate, &entry.Size.Lines, &entry.Size.Chars, &entry.Size.Ratio, &entry.Significant, &entry.Diff)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entry)
+	}
+
+	return result, rows.Err()
+}
+
+// printHistory lists the recorded changes, with their diffs if showDiff is
+// set
+func printHistory(out io.Writer, db *sql.DB, scanUrl string, limit int, showDiff bool) error {
+	entries, err := getHistory(db, scanUrl, limit)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		status := "notified"
+		if !entry.Significant {
+			status = "below threshold"
+		}
+		fmt.Fprintf(out, "%s %s: %d lines, %d characters changed, similarity %.2f, %s\n",
+			entry.ToDate, entry.URL, entry.Size.Lines, entry.Size.Chars, entry.Size.Ratio, status)
+		if showDiff {
+			fmt.Fprintf(out, "%s\n", entry.Diff)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"WD/difflib"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasureChange(t *testing.T) {
+	size := measureChange("<p>Teh price</p>\n<p>10 €</p>", "<p>The price</p>\n<p>10 €</p>", diffOptions{})
+	assert.Equal(t, 1, size.Lines)
+	assert.Equal(t, 1, size.Chars)
+	assert.Equal(t, 0.5, size.Ratio)
+
+	size = measureChange("a\nb\nc\nd", "a\nx\ny\nz\nd", diffOptions{Algorithm: "myers"})
+	assert.Equal(t, 3, size.Lines)
+	assert.Equal(t, 4, size.Chars)
+	assert.InDelta(t, 4.0/9, size.Ratio, 0.0001)
+
+	assert.Equal(t, changeSize{Ratio: 1}, measureChange("", "", diffOptions{}))
+	assert.Equal(t, changeSize{Lines: 1, Chars: 3, Ratio: 0}, measureChange("", "new", diffOptions{}))
+}
+
+func TestMeasureChangeRatio(t *testing.T) {
+	// The ratio is the one of SequenceMatcher with every algorithm
+	oldContent, newContent := "a\nb\nc\nd\ne", "a\nc\nb\nd\nx"
+	expected := difflib.NewMatcher(difflib.SplitLines(oldContent), difflib.SplitLines(newContent)).Ratio()
+	for algorithm := range diffAlgorithms {
+		size := measureChange(oldContent, newContent, diffOptions{Algorithm: algorithm})
+		assert.Equal(t, expected, size.Ratio, "Algorithm %q", algorithm)
+	}
+}
+
+func TestMeasureChangeIgnoreCase(t *testing.T) {
+	size := measureChange("Price: 10 EUR\nStock", "PRICE: 10 eur\nStock", diffOptions{ignoreCase: true})
+	assert.Equal(t, changeSize{Ratio: 1}, size)
+
+	db := openTestDB(t)
+	n := &recordingNotifier{}
+	w := defaultWatch()
+	w.Normalize.IgnoreCase = true
+	w.Threshold.MinLines = 1
+	c := change{URL: "http://www.test.com", FromDate: "2026-01-01 10:00:00", ToDate: "2026-01-01 11:00:00", Text: "case diff"}
+	err := recordChange(context.Background(), db, []notifier{n}, w, "Price: 10 EUR\nStock", "PRICE: 10 eur\nStock", c)
+	require.NoError(t, err, "Expected no error")
+	assert.Empty(t, n.changes)
+}
+
+func TestThresholdReached(t *testing.T) {
+	size := changeSize{Lines: 2, Chars: 10, Ratio: 0.9}
+	assert.True(t, thresholdOptions{}.reached(size))
+	assert.True(t, thresholdOptions{MinLines: 2, MinChars: 10, MaxRatio: 0.9}.reached(size))
+	assert.False(t, thresholdOptions{MinLines: 3}.reached(size))
+	assert.False(t, thresholdOptions{MinChars: 11}.reached(size))
+	assert.False(t, thresholdOptions{MaxRatio: 0.8}.reached(size))
+}
+
+func TestThresholdValidate(t *testing.T) {
+	assert.NoError(t, thresholdOptions{MinLines: 1, MinChars: 1, MaxRatio: 1}.validate())
+	assert.EqualError(t, thresholdOptions{MinLines: -1}.validate(), "Threshold minLines must not be negative")
+	assert.EqualError(t, thresholdOptions{MinChars: -1}.validate(), "Threshold minChars must not be negative")
+	assert.EqualError(t, thresholdOptions{MaxRatio: 1.5}.validate(), "Threshold maxRatio must be between 0 and 1")
+}
+
+func TestRecordChange(t *testing.T) {
+	db := openTestDB(t)
+	n := &recordingNotifier{}
+	w := defaultWatch()
+	w.Name = "shop"
+	w.Threshold.MinChars = 5
+
+	c := change{URL: "http://www.test.com", FromDate: "2026-01-01 10:00:00", ToDate: "2026-01-01 11:00:00", Text: "typo diff"}
+	err := recordChange(context.Background(), db, []notifier{n}, w, "Teh price", "The price", c)
+	require.NoError(t, err, "Expected no error")
+	assert.Empty(t, n.changes)
+
+	c = change{URL: "http://www.test.com", FromDate: "2026-01-01 11:00:00", ToDate: "2026-01-01 12:00:00", Text: "price diff"}
+	err = recordChange(context.Background(), db, []notifier{n}, w, "The price", "The new price is 12 €", c)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, []change{c}, n.changes)
+
+	history, err := getHistory(db, "http://www.test.com", 10)
+	require.NoError(t, err, "Expected no error")
+	require.Len(t, history, 2)
+	assert.Equal(t, "price diff", history[0].Diff)
+	assert.True(t, history[0].Significant)
+	assert.Equal(t, "typo diff", history[1].Diff)
+	assert.False(t, history[1].Significant)
+	assert.Equal(t, changeSize{Lines: 1, Chars: 1, Ratio: 0}, history[1].Size)
+
+	history, err = getHistory(db, "http://www.other.com", 10)
+	require.NoError(t, err, "Expected no error")
+	assert.Empty(t, history)
+
+	history, err = getHistory(db, "", 1)
+	require.NoError(t, err, "Expected no error")
+	assert.Len(t, history, 1)
+}
+
+func TestPrintHistory(t *testing.T) {
+	db := openTestDB(t)
+	w := defaultWatch()
+	w.Threshold.MinLines = 2
+
+	c := change{URL: "http://www.test.com", FromDate: "2026-01-01 10:00:00", ToDate: "2026-01-01 11:00:00", Text: "-a\n+b\n"}
+	err := recordChange(context.Background(), db, nil, w, "a\nc", "b\nc", c)
+	require.NoError(t, err, "Expected no error")
+
+	var out bytes.Buffer
+	err = printHistory(&out, db, "", 10, false)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "2026-01-01 11:00:00 http://www.test.com: 1 lines, 1 characters changed, similarity 0.50, below threshold\n", out.String())
+
+	out.Reset()
+	err = printHistory(&out, db, "http://www.test.com", 10, true)
+	require.NoError(t, err, "Expected no error")
+	assert.Contains(t, out.String(), "below threshold\n-a\n+b\n\n")
+}
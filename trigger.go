@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+const (
+	triggerAppear    = "appear"
+	triggerDisappear = "disappear"
+	triggerChange    = "change"
+)
+
+// triggerContext is the number of characters shown around a match
+const triggerContext = 80
+
+// triggerOptions notify when a regular expression starts or stops matching
+// the content, or when the text it captures changes
+type triggerOptions struct {
+	// Name labels the trigger in notifications, defaults to the pattern
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+	// On is the event to notify about: appear (default), disappear or
+	// change. The captured text is the text of the groups of the pattern,
+	// or the whole match if it has none.
+	On string `yaml:"on"`
+}
+
+func (options triggerOptions) validate() error {
+	if options.Pattern == "" {
+		return fmt.Errorf("Please specify a trigger pattern")
+	}
+
+	_, err := regexp.Compile(options.Pattern)
+	if err != nil {
+		return fmt.Errorf("Invalid trigger pattern %q: %s", options.Pattern, err)
+	}
+
+	switch options.On {
+	case "", triggerAppear, triggerDisappear, triggerChange:
+		return nil
+	}
+
+	return fmt.Errorf("Unknown trigger event: %s", options.On)
+}
+
+func (options triggerOptions) label() string {
+	if options.Name != "" {
+		return options.Name
+	}
+
+	return options.Pattern
+}
+
+// triggerMatch is the first match of a trigger pattern with the text of the
+// line around it
+type triggerMatch struct {
+	Before   string
+	Text     string
+	After    string
+	Captured string
+}
+
+func findTrigger(re *regexp.Regexp, content string) (triggerMatch, bool) {
+	loc := re.FindStringSubmatchIndex(content)
+	if loc == nil {
+		return triggerMatch{}, false
+	}
+
+	match := triggerMatch{Text: content[loc[0]:loc[1]]}
+	if re.NumSubexp() == 0 {
+		match.Captured = match.Text
+	} else {
+		var groups []string
+		for i := 2; i < len(loc); i += 2 {
+			if loc[i] >= 0 {
+				groups = append(groups, content[loc[i]:loc[i+1]])
+			}
+		}
+		match.Captured = strings.Join(groups, " ")
+	}
+
+	start := strings.LastIndex(content[:loc[0]], "\n") + 1
+	end := len(content)
+	if i := strings.Index(content[loc[1]:], "\n"); i >= 0 {
+		end = loc[1] + i
+	}
+	match.Before = strings.TrimLeft(content[start:loc[0]], " \t")
+	if before := []rune(match.Before); len(before) > triggerContext {
+		match.Before = "…" + string(before[len(before)-triggerContext:])
+	}
+	match.After = strings.TrimRight(content[loc[1]:end], " \t\r")
+	if after := []rune(match.After); len(after) > triggerContext {
+		match.After = string(after[:triggerContext]) + "…"
+	}
+
+	return match, true
+}
+
+// triggerEvent reports a trigger which fired between two crawls
+type triggerEvent struct {
+	Trigger string
+	// Event is appeared, disappeared or changed
+	Event string
+	Old   string
+	New   string
+	// Match is the current match, or the previous one if the pattern
+	// disappeared
+	Match triggerMatch
+}
+
+// evaluateTriggers returns the triggers which fired between the old and the
+// new content
+func evaluateTriggers(triggers []triggerOptions, oldContent string, newContent string) ([]triggerEvent, error) {
+	var events []triggerEvent
+	for _, t := range triggers {
+		re, err := regexp.Compile(t.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trigger pattern %q: %s", t.Pattern, err)
+		}
+
+		oldMatch, oldFound := findTrigger(re, oldContent)
+		newMatch, newFound := findTrigger(re, newContent)
+		event := triggerEvent{Trigger: t.label(), Old: oldMatch.Captured, New: newMatch.Captured, Match: newMatch}
+		switch t.On {
+		case triggerDisappear:
+			if !oldFound || newFound {
+				continue
+			}
+			event.Event = "disappeared"
+			event.Match = oldMatch
+		case triggerChange:
+			if oldFound == newFound && oldMatch.Captured == newMatch.Captured {
+				continue
+			}
+			event.Event = "changed"
+			if !newFound {
+				event.Match = oldMatch
+			}
+		default:
+			if oldFound || !newFound {
+				continue
+			}
+			event.Event = "appeared"
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func (e triggerEvent) summary() string {
+	if e.Event == "changed" {
+		return fmt.Sprintf("Trigger %q changed from %q to %q", e.Trigger, e.Old, e.New)
+	}
+
+	return fmt.Sprintf("Trigger %q %s", e.Trigger, e.Event)
+}
+
+// triggerReport describes the fired triggers with the context of their
+// matches, in front of the diff
+func triggerReport(events []triggerEvent) differences {
+	var report differences
+	for _, e := range events {
+		m := e.Match
+		report.text += fmt.Sprintf("%s: %s%s%s\n", e.summary(), m.Before, m.Text, m.After)
+		report.html += fmt.Sprintf("<p><b>%s</b>: %s<mark>%s</mark>%s</p>", html.EscapeString(e.summary()),
+			html.EscapeString(m.Before), html.EscapeString(m.Text), html.EscapeString(m.After))
+	}
+
+	return report
+}
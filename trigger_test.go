@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindTrigger(t *testing.T) {
+	content := "<h1>Shop</h1>\n  <p>Version 1.2.3 released</p>\n"
+	match, found := findTrigger(regexp.MustCompile(`Version (\d+)\.(\d+)`), content)
+	require.True(t, found)
+	assert.Equal(t, triggerMatch{Before: "<p>", Text: "Version 1.2", After: ".3 released</p>", Captured: "1 2"}, match)
+
+	match, found = findTrigger(regexp.MustCompile(`Shop`), content)
+	require.True(t, found)
+	assert.Equal(t, triggerMatch{Before: "<h1>", Text: "Shop", After: "</h1>", Captured: "Shop"}, match)
+
+	_, found = findTrigger(regexp.MustCompile(`Sold out`), content)
+	assert.False(t, found)
+
+	long := strings.Repeat("a", 100) + "match" + strings.Repeat("b", 100)
+	match, _ = findTrigger(regexp.MustCompile(`match`), long)
+	assert.Equal(t, "…"+strings.Repeat("a", triggerContext), match.Before)
+	assert.Equal(t, strings.Repeat("b", triggerContext)+"…", match.After)
+}
+
+func TestEvaluateTriggers(t *testing.T) {
+	triggers := []triggerOptions{
+		{Name: "open", Pattern: `Registration open`},
+		{Pattern: `Sold out`, On: triggerDisappear},
+		{Name: "version", Pattern: `Version ([\d.]+)`, On: triggerChange},
+	}
+
+	events, err := evaluateTriggers(triggers, "Sold out\nVersion 1.2\n", "Registration open\nVersion 1.3\n")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, []triggerEvent{
+		{Trigger: "open", Event: "appeared", New: "Registration open", Match: triggerMatch{Text: "Registration open", Captured: "Registration open"}},
+		{Trigger: "Sold out", Event: "disappeared", Old: "Sold out", Match: triggerMatch{Text: "Sold out", Captured: "Sold out"}},
+		{Trigger: "version", Event: "changed", Old: "1.2", New: "1.3", Match: triggerMatch{Text: "Version 1.3", Captured: "1.3"}},
+	}, events)
+
+	// Nothing fires in the opposite direction
+	events, err = evaluateTriggers(triggers[:2], "Registration open\n", "Sold out\n")
+	require.NoError(t, err, "Expected no error")
+	assert.Empty(t, events)
+
+	events, err = evaluateTriggers(triggers[2:], "Version 1.2\n", "Version 1.2, other text\n")
+	require.NoError(t, err, "Expected no error")
+	assert.Empty(t, events)
+
+	events, err = evaluateTriggers(triggers[2:], "Version 1.2\n", "")
+	require.NoError(t, err, "Expected no error")
+	require.Len(t, events, 1)
+	assert.Equal(t, "Version 1.2", events[0].Match.Text)
+
+	_, err = evaluateTriggers([]triggerOptions{{Pattern: `(`}}, "", "")
+	assert.Error(t, err)
+}
+
+func TestTriggerReport(t *testing.T) {
+	events := []triggerEvent{
+		{Trigger: "Sold out", Event: "disappeared", Match: triggerMatch{Before: "<b>", Text: "Sold out", After: "</b>"}},
+		{Trigger: "version", Event: "changed", Old: "1.2", New: "1.3", Match: triggerMatch{Text: "Version 1.3"}},
+	}
+	report := triggerReport(events)
+	assert.Equal(t, "Trigger \"Sold out\" disappeared: <b>Sold out</b>\nTrigger \"version\" changed from \"1.2\" to \"1.3\": Version 1.3\n", report.text)
+	assert.Equal(t, "<p><b>Trigger &#34;Sold out&#34; disappeared</b>: &lt;b&gt;<mark>Sold out</mark>&lt;/b&gt;</p>"+
+		"<p><b>Trigger &#34;version&#34; changed from &#34;1.2&#34; to &#34;1.3&#34;</b>: <mark>Version 1.3</mark></p>", report.html)
+
+	assert.Equal(t, differences{}, triggerReport(nil))
+}
+
+func TestTriggerValidate(t *testing.T) {
+	assert.NoError(t, triggerOptions{Pattern: "Sold out", On: triggerDisappear}.validate())
+	assert.EqualError(t, triggerOptions{}.validate(), "Please specify a trigger pattern")
+	assert.Contains(t, triggerOptions{Pattern: "("}.validate().Error(), "Invalid trigger pattern \"(\"")
+	assert.EqualError(t, triggerOptions{Pattern: "a", On: "vanish"}.validate(), "Unknown trigger event: vanish")
+}
+
+func TestRecordChangeTriggersOnly(t *testing.T) {
+	db := openTestDB(t)
+	n := &recordingNotifier{}
+	w := defaultWatch()
+	w.Triggers = []triggerOptions{{Pattern: "Sold out", On: triggerDisappear}}
+	w.TriggersOnly = true
+
+	c := change{URL: "http://www.test.com", FromDate: "2026-01-01 10:00:00", ToDate: "2026-01-01 11:00:00", Text: "-10 €\n+12 €\n"}
+	err := recordChange(context.Background(), db, []notifier{n}, w, "10 €\n", "12 €\n", c)
+	require.NoError(t, err, "Expected no error")
+	assert.Empty(t, n.changes)
+
+	events, err := evaluateTriggers(w.Triggers, "Sold out\n", "12 €\n")
+	require.NoError(t, err, "Expected no error")
+	c.Triggers = events
+	err = recordChange(context.Background(), db, []notifier{n}, w, "Sold out\n", "12 €\n", c)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, []change{c}, n.changes)
+
+	history, err := getHistory(db, "", 10)
+	require.NoError(t, err, "Expected no error")
+	require.Len(t, history, 2)
+	assert.Equal(t, "Trigger \"Sold out\" disappeared: Sold out\n-10 €\n+12 €\n", history[0].Diff)
+
+	diffs := emailDifferences(c)
+	assert.True(t, strings.HasPrefix(diffs.text, "Trigger \"Sold out\" disappeared: Sold out\n"))
+	assert.True(t, strings.HasPrefix(diffs.html, "<p><b>Trigger"))
+}
+
+func TestWebhookNotifierTriggersAndValue(t *testing.T) {
+	var received webhookPayload
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err, "Expected no error")
+		err = json.Unmarshal(body, &received)
+		require.NoError(t, err, "Expected no error")
+	}))
+	defer func() { testServer.Close() }()
+
+	n, err := newWebhookNotifier(notifierOptions{URL: testServer.URL})
+	require.NoError(t, err, "Expected no error")
+
+	c := webhookChange
+	c.Triggers = []triggerEvent{{Trigger: "version", Event: "changed", Old: "1.2", New: "1.3", Match: triggerMatch{Before: "<p>", Text: "Version 1.3", After: "</p>"}}}
+	c.Value = &valueChange{Name: "Price", Previous: floatPointer(120), Current: 99.9, Conditions: []string{"below 100"}}
+	require.NoError(t, n.Notify(context.Background(), c))
+	assert.Equal(t, &webhookValue{Name: "Price", Previous: floatPointer(120), Current: 99.9, Conditions: []string{"below 100"}}, received.Value)
+	assert.Equal(t, []webhookTrigger{{Name: "version", Event: "changed", Old: "1.2", New: "1.3", Context: "<p>Version 1.3</p>"}}, received.Triggers)
+	assert.Equal(t, sendEmailDiff.text, received.Diff)
+}
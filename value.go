@@ -0,0 +1,349 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// numberPattern finds the first number of a text, with its grouping and
+// decimal separators
+var numberPattern = regexp.MustCompile(`[-+\x{2212}]?\d(?:[\d.,'\x{2019}\x{a0}\x{202f}]*\d)?`)
+
+// commaDecimalLanguages write numbers like 1.299,00
+var commaDecimalLanguages = map[string]bool{
+	"bg": true, "ca": true, "cs": true, "da": true, "de": true, "el": true, "es": true, "et": true,
+	"fi": true, "fr": true, "hr": true, "hu": true, "id": true, "it": true, "lt": true, "lv": true,
+	"nb": true, "nl": true, "no": true, "pl": true, "pt": true, "ro": true, "ru": true, "sk": true,
+	"sl": true, "sr": true, "sv": true, "tr": true, "uk": true,
+}
+
+// dotDecimalLocales write numbers like 1'299.00 although their language
+// uses a comma elsewhere
+var dotDecimalLocales = map[string]bool{"de-ch": true, "it-ch": true, "rm-ch": true}
+
+// valueOptions extract a number like a price, a stock count or a version
+// from the page and decide when it is reported
+type valueOptions struct {
+	// Name labels the value in notifications
+	Name string `yaml:"name"`
+	// Selector is a CSS selector, the text of the first matching element is
+	// used. Without it the text of the compared content is used.
+	Selector string `yaml:"selector"`
+	// Pattern is a regular expression finding the number in the text, its
+	// first group if it has one. Defaults to the first number.
+	Pattern string `yaml:"pattern"`
+	// Locale like de-DE decides whether "," or "." separates the decimals,
+	// without it the separator is guessed
+	Locale string `yaml:"locale"`
+	// Conditions like "below 100", "above 10", "increased", "decreased",
+	// "changed" or "changed by more than 5%" notify when they are met
+	Conditions []string `yaml:"conditions"`
+}
+
+func (options valueOptions) enabled() bool {
+	return options.Name != "" || options.Selector != "" || options.Pattern != "" || options.Locale != "" || len(options.Conditions) > 0
+}
+
+func (options valueOptions) validate() error {
+	if options.Selector != "" {
+		_, err := cascadia.Compile(options.Selector)
+		if err != nil {
+			return fmt.Errorf("Invalid value selector %q: %s", options.Selector, err)
+		}
+	}
+
+	if options.Pattern != "" {
+		_, err := regexp.Compile(options.Pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid value pattern %q: %s", options.Pattern, err)
+		}
+	}
+
+	for _, condition := range options.Conditions {
+		_, err := parseValueCondition(condition)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (options valueOptions) label() string {
+	if options.Name != "" {
+		return options.Name
+	}
+
+	return "Value"
+}
+
+type valueCondition struct {
+	text    string
+	kind    string
+	amount  float64
+	percent bool
+}
+
+func parseValueCondition(text string) (valueCondition, error) {
+	condition := valueCondition{text: text}
+	fields := strings.Fields(strings.ToLower(text))
+	var amount string
+	switch {
+	case len(fields) == 1 && (fields[0] == "increased" || fields[0] == "decreased" || fields[0] == "changed"):
+		condition.kind = fields[0]
+		return condition, nil
+	case len(fields) == 2 && (fields[0] == "below" || fields[0] == "above"):
+		condition.kind = fields[0]
+		amount = fields[1]
+	case len(fields) == 5 && strings.Join(fields[:4], " ") == "changed by more than":
+		condition.kind = "changedBy"
+		amount = strings.TrimSuffix(fields[4], "%")
+		condition.percent = amount != fields[4]
+	default:
+		return condition, fmt.Errorf("Invalid value condition %q", text)
+	}
+
+	var err error
+	condition.amount, err = strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return condition, fmt.Errorf("Invalid value condition %q", text)
+	}
+
+	return condition, nil
+}
+
+// met tells whether the condition is met by the current value. Limits are
+// only met when they are crossed, so a price below the limit is reported
+// once.
+func (c valueCondition) met(previous *float64, current float64) bool {
+	switch c.kind {
+	case "below":
+		return current < c.amount && (previous == nil || *previous >= c.amount)
+	case "above":
+		return current > c.amount && (previous == nil || *previous <= c.amount)
+	}
+
+	if previous == nil {
+		return false
+	}
+
+	switch c.kind {
+	case "increased":
+		return current > *previous
+	case "decreased":
+		return current < *previous
+	case "changed":
+		return current != *previous
+	}
+
+	difference := math.Abs(current - *previous)
+	if c.percent {
+		if *previous == 0 {
+			return difference > 0
+		}
+		return difference/math.Abs(*previous)*100 > c.amount
+	}
+
+	return difference > c.amount
+}
+
+// parseNumber parses a number written with the conventions of locale, or
+// guesses them if locale is empty
+func parseNumber(text string, locale string) (float64, error) {
+	number := strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "", "\u2019", "", "\u2212", "-").Replace(strings.TrimSpace(text))
+
+	decimal := guessDecimalSeparator(number)
+	if locale != "" {
+		locale = strings.ToLower(strings.Replace(locale, "_", "-", 1))
+		language := strings.SplitN(locale, "-", 2)[0]
+		decimal = "."
+		if commaDecimalLanguages[language] && !dotDecimalLocales[locale] {
+			decimal = ","
+		}
+	}
+
+	group := ","
+	if decimal == "," {
+		group = "."
+	}
+	number = strings.Replace(strings.Replace(number, group, "", -1), decimal, ".", 1)
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to parse number %q", text)
+	}
+
+	return value, nil
+}
+
+// guessDecimalSeparator picks the last separator if both are used. A single
+// separator followed by exactly three digits is taken as grouping.
+func guessDecimalSeparator(number string) string {
+	last := strings.LastIndexAny(number, ".,")
+	if last < 0 {
+		return "."
+	}
+
+	separator := number[last : last+1]
+	if strings.ContainsAny(number[:last], ".,") {
+		if strings.Contains(number[:last], separator) {
+			// 1,299,000 or 1.299.000
+			return strings.Replace(".,", separator, "", 1)
+		}
+		return separator
+	}
+
+	if len(number)-last-1 == 3 {
+		return strings.Replace(".,", separator, "", 1)
+	}
+
+	return separator
+}
+
+// extractValue finds the value of w in the raw body or the compared content
+func extractValue(w watch, body string, content string) (float64, error) {
+	options := w.Value
+	text := content
+	if options.Selector != "" {
+		document, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+		if err != nil {
+			return 0, fmt.Errorf("Unable to parse HTML: %s", err)
+		}
+
+		selection := document.Find(options.Selector)
+		if selection.Length() == 0 {
+			return 0, noMatchError{kind: "Value selector", expression: options.Selector}
+		}
+		text = selection.First().Text()
+	} else if w.Extract.Format != formatJSON && w.Extract.Format != formatXML && !w.Extract.Text && w.Extract.XPathResult != xpathResultText {
+		var err error
+		text, err = htmlToText(content)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	pattern := numberPattern
+	if options.Pattern != "" {
+		pattern = regexp.MustCompile(options.Pattern)
+	}
+
+	match := pattern.FindStringSubmatch(text)
+	if match == nil {
+		expression := options.Pattern
+		if expression == "" {
+			expression = numberPattern.String()
+		}
+		return 0, noMatchError{kind: "Value pattern", expression: expression}
+	}
+
+	found := match[0]
+	if len(match) > 1 {
+		found = match[1]
+	}
+	if options.Pattern != "" {
+		// The pattern may capture a currency or unit next to the number
+		found = numberPattern.FindString(found)
+	}
+
+	return parseNumber(found, options.Locale)
+}
+
+// valueChange reports the value of a watch at two crawls and the conditions
+// which are met
+type valueChange struct {
+	Name     string
+	Previous *float64
+	Current  float64
+	// Conditions are the met conditions
+	Conditions []string
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func (v *valueChange) summary() string {
+	if v == nil {
+		return ""
+	}
+
+	summary := fmt.Sprintf("%s: %s", v.Name, formatValue(v.Current))
+	if v.Previous != nil {
+		summary = fmt.Sprintf("%s: %s -> %s", v.Name, formatValue(*v.Previous), formatValue(v.Current))
+	}
+	if len(v.Conditions) > 0 {
+		summary += " (" + strings.Join(v.Conditions, ", ") + ")"
+	}
+
+	return summary
+}
+
+// valueReport describes the value in front of the diff
+func valueReport(v *valueChange) differences {
+	if v == nil {
+		return differences{}
+	}
+
+	return differences{text: v.summary() + "\n", html: "<p><b>" + html.EscapeString(v.summary()) + "</b></p>"}
+}
+
+// checkValue extracts the value of w, stores it in the value history and
+// compares it to the previously stored one. It returns nil if w has no
+// value.
+func checkValue(db *sql.DB, w watch, body string, content string) (*valueChange, error) {
+	if !w.Value.enabled() {
+		return nil, nil
+	}
+
+	current, err := extractValue(w, body, content)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := getLastValue(db, w.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("INSERT INTO valueHistory(url, crawlTime, value) values(?, datetime('now'), ?)", w.URL, current)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &valueChange{Name: w.Value.label(), Previous: previous, Current: current}
+	for _, text := range w.Value.Conditions {
+		condition, err := parseValueCondition(text)
+		if err != nil {
+			return nil, err
+		}
+		if condition.met(previous, current) {
+			result.Conditions = append(result.Conditions, condition.text)
+		}
+	}
+
+	return result, nil
+}
+
+// getLastValue returns the last stored value of scanUrl, nil if there is
+// none
+func getLastValue(db *sql.DB, scanUrl string) (*float64, error) {
+	var value float64
+	err := db.QueryRow("SELECT value FROM valueHistory WHERE url = ? ORDER BY crawlTime DESC, rowid DESC LIMIT 1", scanUrl).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &value, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func floatPointer(value float64) *float64 {
+	return &value
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		text     string
+		locale   string
+		expected float64
+	}{
+		{"1.299,00", "de-DE", 1299},
+		{"1.299", "de-DE", 1299},
+		{"1,5", "de", 1.5},
+		{"1,299.00", "en-US", 1299},
+		{"1,299", "en_US", 1299},
+		{"1'299.50", "de-CH", 1299.5},
+		{"1'299.50", "it_CH", 1299.5},
+		{"1 234,50", "fr-CH", 1234.5},
+		{"1\u202f234,50", "fr-CH", 1234.5},
+		{"1\u202f299,95", "fr-FR", 1299.95},
+		{"\u221212,5", "de-DE", -12.5},
+		{"1.299,00", "", 1299},
+		{"1,299.00", "", 1299},
+		{"1.299.000", "", 1299000},
+		{"1.299", "", 1299},
+		{"12,99", "", 12.99},
+		{"9.5", "", 9.5},
+		{"42", "", 42},
+	}
+
+	for _, test := range tests {
+		value, err := parseNumber(test.text, test.locale)
+		require.NoError(t, err, test.text)
+		assert.Equal(t, test.expected, value, test.text)
+	}
+
+	_, err := parseNumber("1,2,3.4.5", "en")
+	assert.EqualError(t, err, "Unable to parse number \"1,2,3.4.5\"")
+}
+
+func TestParseValueCondition(t *testing.T) {
+	condition, err := parseValueCondition("Below 99.5")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, valueCondition{text: "Below 99.5", kind: "below", amount: 99.5}, condition)
+
+	condition, err = parseValueCondition("changed by more than 5%")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, valueCondition{text: "changed by more than 5%", kind: "changedBy", amount: 5, percent: true}, condition)
+
+	for _, text := range []string{"below", "below cheap", "rises", "changed by more than", "changed by at least 5"} {
+		_, err = parseValueCondition(text)
+		assert.EqualError(t, err, "Invalid value condition \""+text+"\"")
+	}
+}
+
+func TestValueConditionMet(t *testing.T) {
+	met := func(text string, previous *float64, current float64) bool {
+		condition, err := parseValueCondition(text)
+		require.NoError(t, err, "Expected no error")
+		return condition.met(previous, current)
+	}
+
+	assert.True(t, met("below 100", nil, 99))
+	assert.True(t, met("below 100", floatPointer(100), 99))
+	assert.False(t, met("below 100", floatPointer(98), 99))
+	assert.False(t, met("below 100", nil, 100))
+	assert.True(t, met("above 10", floatPointer(10), 11))
+	assert.False(t, met("above 10", floatPointer(11), 12))
+
+	assert.True(t, met("increased", floatPointer(1), 2))
+	assert.False(t, met("increased", nil, 2))
+	assert.True(t, met("decreased", floatPointer(2), 1))
+	assert.True(t, met("changed", floatPointer(2), 1))
+	assert.False(t, met("changed", floatPointer(2), 2))
+
+	assert.True(t, met("changed by more than 5%", floatPointer(100), 94))
+	assert.False(t, met("changed by more than 5%", floatPointer(100), 105))
+	assert.True(t, met("changed by more than 5%", floatPointer(0), 1))
+	assert.True(t, met("changed by more than 2", floatPointer(10), 7))
+	assert.False(t, met("changed by more than 2", floatPointer(10), 8))
+}
+
+func TestExtractValue(t *testing.T) {
+	body := "<html><body><h1>Product 2</h1><span class=\"price\">Now 1.299,00 €</span><p>Stock: 17 left</p></body></html>"
+
+	w := defaultWatch()
+	w.Value = valueOptions{Selector: ".price", Locale: "de-DE"}
+	value, err := extractValue(w, body, body)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, 1299.0, value)
+
+	// Without a selector the text of the compared content is searched, the
+	// number in <h1> is not taken from the markup
+	w.Value = valueOptions{Pattern: `Stock: (\d+)`}
+	value, err = extractValue(w, body, body)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, 17.0, value)
+
+	w.Value = valueOptions{Pattern: `Price: (\S+ €)`}
+	value, err = extractValue(w, body, "<p>Price: 12,50 €</p>")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, 12.5, value)
+
+	w.Value = valueOptions{Selector: ".missing"}
+	_, err = extractValue(w, body, body)
+	assert.EqualError(t, err, "Value selector \".missing\" no longer matches anything")
+
+	w.Value = valueOptions{Pattern: `Sold: (\d+)`}
+	_, err = extractValue(w, body, body)
+	assert.EqualError(t, err, "Value pattern \"Sold: (\\\\d+)\" no longer matches anything")
+
+	w.Extract.Format = formatJSON
+	w.Value = valueOptions{Pattern: `"price": (\d+)`}
+	value, err = extractValue(w, "", "{\n  \"price\": 25\n}")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, 25.0, value)
+}
+
+func TestCheckValue(t *testing.T) {
+	db := openTestDB(t)
+	w := defaultWatch()
+	w.URL = "http://www.test.com"
+	w.Value = valueOptions{Name: "Price", Selector: ".price", Conditions: []string{"below 100", "decreased"}}
+
+	value, err := checkValue(db, w, "<b class=\"price\">120 €</b>", "")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, &valueChange{Name: "Price", Current: 120}, value)
+	assert.Equal(t, "Price: 120", value.summary())
+
+	value, err = checkValue(db, w, "<b class=\"price\">99,90 €</b>", "")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, &valueChange{Name: "Price", Previous: floatPointer(120), Current: 99.9, Conditions: []string{"below 100", "decreased"}}, value)
+	assert.Equal(t, differences{
+		text: "Price: 120 -> 99.9 (below 100, decreased)\n",
+		html: "<p><b>Price: 120 -&gt; 99.9 (below 100, decreased)</b></p>",
+	}, valueReport(value))
+
+	last, err := getLastValue(db, w.URL)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, floatPointer(99.9), last)
+
+	w.Value = valueOptions{}
+	value, err = checkValue(db, w, "", "")
+	require.NoError(t, err, "Expected no error")
+	assert.Nil(t, value)
+	assert.Equal(t, differences{}, valueReport(nil))
+}
+
+func TestValueValidate(t *testing.T) {
+	assert.NoError(t, valueOptions{Selector: ".price", Pattern: `(\d+)`, Conditions: []string{"increased"}}.validate())
+	assert.Contains(t, valueOptions{Selector: "<"}.validate().Error(), "Invalid value selector")
+	assert.Contains(t, valueOptions{Pattern: "("}.validate().Error(), "Invalid value pattern")
+	assert.EqualError(t, valueOptions{Conditions: []string{"cheap"}}.validate(), "Invalid value condition \"cheap\"")
+}
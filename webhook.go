@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	notifierWebhook = "webhook"
+
+	// signatureHeader carries the HMAC-SHA256 of the request body
+	signatureHeader = "X-Signature-256"
+)
+
+// webhookPayload is the JSON body POSTed by the webhook notifier
+type webhookPayload struct {
+	Watch        string `json:"watch"`
+	URL          string `json:"url"`
+	OldCrawlTime string `json:"oldCrawlTime"`
+	NewCrawlTime string `json:"newCrawlTime"`
+	Diff         string `json:"diff"`
+	Added        int    `json:"added"`
+	Removed      int    `json:"removed"`
+	Changed      int    `json:"changed"`
+	ContentHash  string `json:"contentHash"`
+	Hunks        []hunk `json:"hunks,omitempty"`
+	// Triggers lists the triggers which fired
+	Triggers []webhookTrigger `json:"triggers,omitempty"`
+	// Value is the extracted value of the watch
+	Value *webhookValue `json:"value,omitempty"`
+}
+
+type webhookValue struct {
+	Name       string   `json:"name"`
+	Previous   *float64 `json:"previous"`
+	Current    float64  `json:"current"`
+	Conditions []string `json:"conditions"`
+}
+
+type webhookTrigger struct {
+	Name    string `json:"name"`
+	Event   string `json:"event"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+	Context string `json:"context"`
+}
+
+// retryPolicy retries failed requests with an exponentially growing delay
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+}
+
+func newRetryPolicy(options notifierOptions) retryPolicy {
+	policy := retryPolicy{retries: 3, backoff: time.Second}
+	if options.Retries != nil {
+		policy.retries = *options.Retries
+	}
+	if options.Backoff > 0 {
+		policy.backoff = options.Backoff
+	}
+
+	return policy
+}
+
+func newNotifierClient(options notifierOptions) *http.Client {
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = time.Second * 10
+	}
+
+	return &http.Client{Timeout: timeout}
+}
+
+// postJSON sends body to url. Network errors and 5xx responses are retried,
+// every other status code outside 2xx fails right away.
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, header http.Header, policy retryPolicy) error {
+	backoff := policy.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := postJSONOnce(ctx, client, url, body, header)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt >= policy.retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func postJSONOnce(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) (bool, error) {
+	request, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request = request.WithContext(ctx)
+
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("Error getting Response: %s", err)
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode >= 500, fmt.Errorf("Incorrect HTTP Status Code: %s", response.Status)
+	}
+
+	return false, nil
+}
+
+// sign returns the value of the signature header for body
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type webhookNotifier struct {
+	url     string
+	secret  string
+	headers map[string]string
+	retry   retryPolicy
+	client  *http.Client
+}
+
+func newWebhookNotifier(options notifierOptions) (notifier, error) {
+	if options.URL == "" {
+		return nil, fmt.Errorf("Please specify the webhook URL")
+	}
+
+	return &webhookNotifier{
+		url:     options.URL,
+		secret:  options.Secret,
+		headers: options.Headers,
+		retry:   newRetryPolicy(options),
+		client:  newNotifierClient(options),
+	}, nil
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, c change) error {
+	var triggers []webhookTrigger
+	for _, e := range c.Triggers {
+		triggers = append(triggers, webhookTrigger{
+			Name:    e.Trigger,
+			Event:   e.Event,
+			Old:     e.Old,
+			New:     e.New,
+			Context: e.Match.Before + e.Match.Text + e.Match.After,
+		})
+	}
+
+	var value *webhookValue
+	if c.Value != nil {
+		value = &webhookValue{Name: c.Value.Name, Previous: c.Value.Previous, Current: c.Value.Current, Conditions: c.Value.Conditions}
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		Watch:        c.Watch,
+		URL:          c.URL,
+		OldCrawlTime: c.FromDate,
+		NewCrawlTime: c.ToDate,
+		Diff:         c.Text,
+		Added:        c.Stats.Added,
+		Removed:      c.Stats.Removed,
+		Changed:      c.Stats.Changed,
+		ContentHash:  c.ContentHash,
+		Hunks:        c.Hunks,
+		Triggers:     triggers,
+		Value:        value,
+	})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	for name, value := range n.headers {
+		header.Set(name, value)
+	}
+	if n.secret != "" {
+		header.Set(signatureHeader, sign(n.secret, body))
+	}
+
+	err = postJSON(ctx, n.client, n.url, body, header, n.retry)
+	if err != nil {
+		return fmt.Errorf("Unable to call webhook: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var webhookChange = change{
+	Watch:       "test",
+	URL:         "https://www.test.com",
+	FromDate:    "2021-03-01 10:00:00",
+	ToDate:      "2021-03-01 11:00:00",
+	Text:        sendEmailDiff.text,
+	HTML:        sendEmailDiff.html,
+	Stats:       changeStats{Added: 2, Removed: 2},
+	ContentHash: contentHash(string(htmlBodyNew)),
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received webhookPayload
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err, "Expected no error")
+
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		assert.Equal(t, sign("secret", body), req.Header.Get(signatureHeader))
+
+		err = json.Unmarshal(body, &received)
+		require.NoError(t, err, "Expected no error")
+		res.WriteHeader(204)
+	}))
+	defer func() { testServer.Close() }()
+
+	n, err := newWebhookNotifier(notifierOptions{Type: notifierWebhook, URL: testServer.URL, Secret: "secret", Headers: map[string]string{"Authorization": "Bearer token"}})
+	require.NoError(t, err, "Expected no error")
+
+	err = n.Notify(context.Background(), webhookChange)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, webhookPayload{
+		Watch:        "test",
+		URL:          "https://www.test.com",
+		OldCrawlTime: "2021-03-01 10:00:00",
+		NewCrawlTime: "2021-03-01 11:00:00",
+		Diff:         sendEmailDiff.text,
+		Added:        2,
+		Removed:      2,
+		ContentHash:  contentHash(string(htmlBodyNew)),
+	}, received)
+}
+
+func TestWebhookNotifierWithoutSecret(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "", req.Header.Get(signatureHeader))
+	}))
+	defer func() { testServer.Close() }()
+
+	n, err := newWebhookNotifier(notifierOptions{URL: testServer.URL})
+	require.NoError(t, err, "Expected no error")
+	require.NoError(t, n.Notify(context.Background(), webhookChange))
+}
+
+func TestWebhookNotifierRetries(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			res.WriteHeader(503)
+		}
+	}))
+	defer func() { testServer.Close() }()
+
+	n, err := newWebhookNotifier(notifierOptions{URL: testServer.URL, Backoff: time.Millisecond})
+	require.NoError(t, err, "Expected no error")
+	require.NoError(t, n.Notify(context.Background(), webhookChange))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestWebhookNotifierGivesUp(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		res.WriteHeader(500)
+	}))
+	defer func() { testServer.Close() }()
+
+	n, err := newWebhookNotifier(notifierOptions{URL: testServer.URL, Retries: intPointer(2), Backoff: time.Millisecond})
+	require.NoError(t, err, "Expected no error")
+
+	err = n.Notify(context.Background(), webhookChange)
+	assert.Equal(t, "Unable to call webhook: Incorrect HTTP Status Code: 500 Internal Server Error", err.Error())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestWebhookNotifierNoRetryOnClientError(t *testing.T) {
+	var calls int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		res.WriteHeader(400)
+	}))
+	defer func() { testServer.Close() }()
+
+	n, err := newWebhookNotifier(notifierOptions{URL: testServer.URL, Backoff: time.Millisecond})
+	require.NoError(t, err, "Expected no error")
+
+	err = n.Notify(context.Background(), webhookChange)
+	assert.Equal(t, "Unable to call webhook: Incorrect HTTP Status Code: 400 Bad Request", err.Error())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestWebhookNotifierCancelledBackoff(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(502)
+	}))
+	defer func() { testServer.Close() }()
+
+	n, err := newWebhookNotifier(notifierOptions{URL: testServer.URL, Backoff: time.Hour})
+	require.NoError(t, err, "Expected no error")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	err = n.Notify(ctx, webhookChange)
+	assert.Equal(t, "Unable to call webhook: Incorrect HTTP Status Code: 502 Bad Gateway", err.Error())
+}
+
+func TestWebhookNotifierOptions(t *testing.T) {
+	_, err := newWebhookNotifier(notifierOptions{Type: notifierWebhook})
+	assert.Equal(t, "Please specify the webhook URL", err.Error())
+
+	policy := newRetryPolicy(notifierOptions{})
+	assert.Equal(t, retryPolicy{retries: 3, backoff: time.Second}, policy)
+
+	policy = newRetryPolicy(notifierOptions{Retries: intPointer(0), Backoff: time.Minute})
+	assert.Equal(t, retryPolicy{retries: 0, backoff: time.Minute}, policy)
+}
+
+func TestSign(t *testing.T) {
+	// Reference value computed with: printf 'body' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=dc46983557fea127b43af721467eb9b3fde2338fe3e14f51952aa8478c13d355", sign("secret", []byte("body")))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+const (
+	formatHTML = "html"
+	formatXML  = "xml"
+
+	// xpathResultNodes compares the markup of the matched nodes,
+	// xpathResultText only their text
+	xpathResultNodes = "nodes"
+	xpathResultText  = "text"
+)
+
+func (options extractOptions) validateXPath() error {
+	switch options.Format {
+	case "", formatHTML, formatXML, formatJSON:
+	default:
+		return fmt.Errorf("Unknown format: %s", options.Format)
+	}
+
+	switch options.XPathResult {
+	case "", xpathResultNodes, xpathResultText:
+	default:
+		return fmt.Errorf("Unknown XPath result: %s", options.XPathResult)
+	}
+
+	if options.XPath == "" {
+		return nil
+	}
+
+	if options.Selector != "" {
+		return fmt.Errorf("Please specify either a CSS selector or an XPath expression")
+	}
+
+	_, err := xpath.Compile(options.XPath)
+	if err != nil {
+		return fmt.Errorf("Invalid XPath expression %q: %s", options.XPath, err)
+	}
+
+	return nil
+}
+
+// extractXPath evaluates the XPath expression against the HTML or XML body.
+// Node sets are returned one node per line, other results as their string
+// value.
+func extractXPath(body string, options extractOptions) (string, error) {
+	expression, err := xpath.Compile(options.XPath)
+	if err != nil {
+		return "", fmt.Errorf("Invalid XPath expression %q: %s", options.XPath, err)
+	}
+
+	var navigator xpath.NodeNavigator
+	var markup func(xpath.NodeNavigator) string
+	if options.Format == formatXML {
+		document, err := xmlquery.Parse(strings.NewReader(body))
+		if err != nil {
+			return "", fmt.Errorf("Unable to parse XML: %s", err)
+		}
+
+		navigator = xmlquery.CreateXPathNavigator(document)
+		markup = func(current xpath.NodeNavigator) string {
+			return current.(*xmlquery.NodeNavigator).Current().OutputXML(true)
+		}
+	} else {
+		document, err := htmlquery.Parse(strings.NewReader(body))
+		if err != nil {
+			return "", fmt.Errorf("Unable to parse HTML: %s", err)
+		}
+
+		navigator = htmlquery.CreateXPathNavigator(document)
+		markup = func(current xpath.NodeNavigator) string {
+			return htmlquery.OutputHTML(current.(*htmlquery.NodeNavigator).Current(), true)
+		}
+	}
+
+	noMatch := noMatchError{kind: "XPath", expression: options.XPath}
+	switch result := expression.Evaluate(navigator).(type) {
+	case *xpath.NodeIterator:
+		var parts []string
+		for result.MoveNext() {
+			current := result.Current()
+			if options.XPathResult == xpathResultText || current.NodeType() == xpath.AttributeNode {
+				parts = append(parts, current.Value())
+			} else {
+				parts = append(parts, markup(current))
+			}
+		}
+
+		if len(parts) == 0 {
+			return "", noMatch
+		}
+
+		return strings.Join(parts, "\n"), nil
+	case string:
+		if result == "" {
+			return "", noMatch
+		}
+
+		return result, nil
+	case float64:
+		return strconv.FormatFloat(result, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(result), nil
+	}
+
+	return "", noMatch
+}
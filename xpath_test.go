@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var htmlSpecs = `<html><body>
+<table id="specs">
+  <tr><th>Weight</th><td>1 kg</td></tr>
+  <tr><th>Price</th><td class="value">12 <b>€</b></td></tr>
+</table>
+<a href="/manual.pdf">Manual</a>
+</body></html>`
+
+var xmlFeed = `<?xml version="1.0"?>
+<feed>
+  <item id="1"><title>First</title></item>
+  <item id="2"><title>Second</title></item>
+</feed>`
+
+func TestExtractXPathNodes(t *testing.T) {
+	content, err := extractXPath(htmlSpecs, extractOptions{XPath: "//th[text()='Price']/following-sibling::td"})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "<td class=\"value\">12 <b>€</b></td>", content)
+}
+
+func TestExtractXPathText(t *testing.T) {
+	content, err := extractXPath(htmlSpecs, extractOptions{XPath: "//table//td", XPathResult: xpathResultText})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "1 kg\n12 €", content)
+
+	content, err = extractXPath(htmlSpecs, extractOptions{XPath: "//a/@href"})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "/manual.pdf", content)
+}
+
+func TestExtractXPathValues(t *testing.T) {
+	content, err := extractXPath(htmlSpecs, extractOptions{XPath: "count(//tr)"})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "2", content)
+
+	content, err = extractXPath(htmlSpecs, extractOptions{XPath: "normalize-space(//th[1])"})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "Weight", content)
+
+	content, err = extractXPath(htmlSpecs, extractOptions{XPath: "boolean(//a)"})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "true", content)
+}
+
+func TestExtractXPathXML(t *testing.T) {
+	content, err := extractXPath(xmlFeed, extractOptions{XPath: "//item[@id='2']", Format: formatXML})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "<item id=\"2\"><title>Second</title></item>", content)
+
+	content, err = extractXPath(xmlFeed, extractOptions{XPath: "//title", Format: formatXML, XPathResult: xpathResultText})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "First\nSecond", content)
+}
+
+func TestExtractXPathNoMatch(t *testing.T) {
+	_, err := extractXPath(htmlSpecs, extractOptions{XPath: "//th[text()='Stock']"})
+	assert.Equal(t, "XPath \"//th[text()='Stock']\" no longer matches anything", err.Error())
+	assert.IsType(t, noMatchError{}, err)
+
+	_, err = extractXPath(htmlSpecs, extractOptions{XPath: "string(//h1)"})
+	assert.IsType(t, noMatchError{}, err)
+}
+
+func TestExtractOptionsValidateXPath(t *testing.T) {
+	assert.NoError(t, extractOptions{XPath: "//td", Format: formatXML, XPathResult: xpathResultText}.validate())
+
+	err := extractOptions{XPath: "//td[", Format: formatHTML}.validate()
+	assert.Contains(t, err.Error(), "Invalid XPath expression \"//td[\"")
+
+	err = extractOptions{XPath: "//td", Selector: "td"}.validate()
+	assert.EqualError(t, err, "Please specify either a CSS selector or an XPath expression")
+
+	err = extractOptions{Format: "yaml"}.validate()
+	assert.EqualError(t, err, "Unknown format: yaml")
+
+	err = extractOptions{XPathResult: "html"}.validate()
+	assert.EqualError(t, err, "Unknown XPath result: html")
+}
+
+func TestProcessContentXPath(t *testing.T) {
+	w := defaultWatch()
+	w.Extract.XPath = "//div[@id='product']//text()[normalize-space(.) != '']"
+	w.Extract.XPathResult = xpathResultText
+	w.Extract.Exclude = []string{"h1"}
+
+	content, err := processContent(w, htmlShop)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "10 €", content)
+}
+
+func TestReportNoMatch(t *testing.T) {
+	w := defaultWatch()
+	w.Name = "Specs"
+	w.Extract.XPath = "//th[text()='Price']"
+	_, extractErr := processContent(w, "<html></html>")
+
+	resultData := []dbRow{
+		{url: "https://www.test.com", crawlTime: "2021-03-02 10:00:00", response: "<html></html>"},
+		{url: "https://www.test.com", crawlTime: "2021-03-01 10:00:00", response: htmlSpecs},
+	}
+
+	// Without notifyOnNoMatch the error is only returned
+	n := &recordingNotifier{}
+	err := reportNoMatch(context.Background(), []notifier{n}, w, resultData, extractErr)
+	assert.Equal(t, extractErr, err)
+	assert.Empty(t, n.changes)
+
+	w.Extract.NotifyOnNoMatch = true
+	err = reportNoMatch(context.Background(), []notifier{n}, w, resultData, extractErr)
+	assert.Equal(t, extractErr, err)
+	require.Len(t, n.changes, 1)
+	assert.Equal(t, "Specs", n.changes[0].Watch)
+	assert.Equal(t, "2021-03-01 10:00:00", n.changes[0].FromDate)
+	assert.Equal(t, "XPath \"//th[text()='Price']\" no longer matches anything, the layout of the page has probably changed.\n", n.changes[0].Text)
+
+	// The previous crawl did not match either, so it was already reported
+	resultData[1].response = "<html></html>"
+	err = reportNoMatch(context.Background(), []notifier{n}, w, resultData, extractErr)
+	assert.Equal(t, extractErr, err)
+	assert.Len(t, n.changes, 1)
+}